@@ -0,0 +1,157 @@
+package inject
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// Reserved is the prefix of the placeholders that take the place of injections
+// while a document is parsed by a backend
+const Reserved = "__porter_inject_"
+
+// placeholderRegexp matches a placeholder, capturing the injection index
+var placeholderRegexp = regexp.MustCompile(Reserved + `(\d+)__`)
+
+// Bindings maps the code inside each injection {{}} to the value injected in
+// its place, e.g. {{ foo.bar }} is bound by the name "foo.bar"
+type Bindings map[string]v.Value
+
+// Injection is the name and the resolved value of an injection. Quoted is
+// true if the injection makes up a whole quoted string, e.g. "{{ foo }}".
+type Injection struct {
+	Name   string
+	Val    v.Value
+	Quoted bool
+}
+
+// Placeholder returns the placeholder of the injection at index i
+func Placeholder(i int) string {
+	return Reserved + strconv.Itoa(i) + "__"
+}
+
+// CheckReserved returns an error if a document contains the reserved text of
+// the placeholders
+func CheckReserved(src string) error {
+	if strings.Contains(src, Reserved) {
+		return fmt.Errorf("Document cannot contain the reserved text %s", Reserved)
+	}
+
+	return nil
+}
+
+// Named calls inject with a resolve function that binds each injection to a
+// value by its name. It is an error for an injection to have no binding, or for
+// a binding to be unused.
+func Named(b Bindings, inject func(resolve func(name string) (v.Value, error)) (v.Value, error)) (v.Value, error) {
+	used := make(map[string]bool)
+
+	res, err := inject(func(name string) (v.Value, error) {
+		val, ok := b[name]
+
+		if !ok {
+			return nil, fmt.Errorf("No value bound to injection %s", name)
+		}
+
+		used[name] = true
+
+		return val, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []string
+
+	for name := range b {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+
+	if len(unused) > 0 {
+		sort.Strings(unused)
+
+		return nil, fmt.Errorf("Bindings not used by any injection: %s", strings.Join(unused, ", "))
+	}
+
+	return res, nil
+}
+
+// Substitute replaces the placeholders in a string with the injected values.
+// If the string is a single placeholder of an injection that is not quoted,
+// the injected value is returned as is.
+func Substitute(str string, injections []Injection) (v.Value, error) {
+	if injections == nil || !strings.Contains(str, Reserved) {
+		return v.String(str), nil
+	}
+
+	if loc := placeholderRegexp.FindStringIndex(str); loc != nil && loc[0] == 0 && loc[1] == len(str) {
+		inj, err := injectionAt(str, injections)
+
+		if err != nil || !inj.Quoted {
+			return inj.Val, err
+		}
+	}
+
+	var err error
+
+	res := placeholderRegexp.ReplaceAllStringFunc(str, func(match string) string {
+		inj, _err := injectionAt(match, injections)
+
+		if _err != nil {
+			if err == nil {
+				err = _err
+			}
+
+			return match
+		}
+
+		s, _err := v.Stringify(inj.Val)
+
+		if _err != nil && err == nil {
+			err = fmt.Errorf("Injection %s inside a string must be a string, number, boolean or null", inj.Name)
+		}
+
+		return s
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.String(res), nil
+}
+
+// injectionAt returns the injection for a placeholder, or an error if the
+// placeholder does not refer to an injection
+func injectionAt(match string, injections []Injection) (Injection, error) {
+	i, err := strconv.Atoi(placeholderRegexp.FindStringSubmatch(match)[1])
+
+	if err != nil || i < 0 || i >= len(injections) {
+		return Injection{}, fmt.Errorf("Unknown injection placeholder %s", match)
+	}
+
+	return injections[i], nil
+}
+
+// ErrorAt creates an error at the line and column of an offset in src
+func ErrorAt(src string, offset int, msg string) error {
+	line, col := 1, 1
+
+	for _, r := range src[:offset] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+
+	return fmt.Errorf("Column %d, Line %d: %s", col, line, msg)
+}
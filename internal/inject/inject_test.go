@@ -0,0 +1,151 @@
+package inject
+
+import (
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type substituteTest struct {
+	name string
+	str  string
+	want v.Value
+	msg  string
+}
+
+var substituteInjections = []Injection{
+	Injection{Name: "port", Val: v.Integer(80)},
+	Injection{Name: "port", Val: v.Integer(80), Quoted: true},
+	Injection{Name: "labels", Val: v.Object{"app": v.String("web")}},
+	Injection{Name: "app", Val: v.String("web")},
+}
+
+var substituteTestsPass = []substituteTest{
+	substituteTest{
+		name: "Substitute: no placeholder",
+		str:  "hello",
+		want: v.String("hello"),
+	},
+	substituteTest{
+		name: "Substitute: whole value",
+		str:  "__porter_inject_0__",
+		want: v.Integer(80),
+	},
+	substituteTest{
+		name: "Substitute: whole object",
+		str:  "__porter_inject_2__",
+		want: v.Object{"app": v.String("web")},
+	},
+	substituteTest{
+		name: "Substitute: quoted value",
+		str:  "__porter_inject_1__",
+		want: v.String("80"),
+	},
+	substituteTest{
+		name: "Substitute: interpolation",
+		str:  "__porter_inject_3__:__porter_inject_0__",
+		want: v.String("web:80"),
+	},
+}
+
+func TestSubstitutePass(t *testing.T) {
+	for _, c := range substituteTestsPass {
+		res, err := Substitute(c.str, substituteInjections)
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+var substituteTestsFail = []substituteTest{
+	substituteTest{
+		name: "Substitute: unknown placeholder",
+		str:  "__porter_inject_7__",
+		msg:  "Unknown injection placeholder __porter_inject_7__",
+	},
+	substituteTest{
+		name: "Substitute: unknown interpolated placeholder",
+		str:  "x-__porter_inject_7__",
+		msg:  "Unknown injection placeholder __porter_inject_7__",
+	},
+	substituteTest{
+		name: "Substitute: interpolated object",
+		str:  "x-__porter_inject_2__",
+		msg:  "Injection labels inside a string must be a string, number, boolean or null",
+	},
+}
+
+func TestSubstituteFail(t *testing.T) {
+	for _, c := range substituteTestsFail {
+		_, err := Substitute(c.str, substituteInjections)
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.str, c.msg, err)
+		}
+	}
+}
+
+func TestNamed(t *testing.T) {
+	inject := func(names ...string) func(resolve func(name string) (v.Value, error)) (v.Value, error) {
+		return func(resolve func(name string) (v.Value, error)) (v.Value, error) {
+			arr := v.Array{}
+
+			for _, name := range names {
+				val, err := resolve(name)
+
+				if err != nil {
+					return nil, err
+				}
+
+				arr = append(arr, val)
+			}
+
+			return arr, nil
+		}
+	}
+
+	b := Bindings{
+		"foo": v.Integer(1),
+		"qux": v.Integer(2),
+		"bar": v.Integer(3),
+	}
+
+	res, err := Named(b, inject("foo", "bar", "qux", "foo"))
+
+	if want := (v.Array{v.Integer(1), v.Integer(3), v.Integer(2), v.Integer(1)}); err != nil || !v.IsEqual(res, want) {
+		t.Errorf("Failed on: all bindings used, %v, %v, %v", res, want, err)
+	}
+
+	_, err = Named(b, inject("foo"))
+
+	if msg := "Bindings not used by any injection: bar, qux"; err == nil || err.Error() != msg {
+		t.Errorf("Failed on: unused bindings, expected %v, got %v", msg, err)
+	}
+
+	_, err = Named(b, inject("baz"))
+
+	if msg := "No value bound to injection baz"; err == nil || err.Error() != msg {
+		t.Errorf("Failed on: unknown name, expected %v, got %v", msg, err)
+	}
+}
+
+func TestErrorAt(t *testing.T) {
+	err := ErrorAt("a: 1\nb: {{foo}}\n", 8, "No value bound to injection foo")
+
+	if msg := "Column 4, Line 2: No value bound to injection foo"; err.Error() != msg {
+		t.Errorf("Failed on: offset 8, expected %v, got %v", msg, err)
+	}
+}
+
+func TestCheckReserved(t *testing.T) {
+	if err := CheckReserved("a: {{foo}}\n"); err != nil {
+		t.Errorf("Failed on: no reserved text, got %v", err)
+	}
+
+	err := CheckReserved("a: __porter_inject_0__\n")
+
+	if msg := "Document cannot contain the reserved text __porter_inject_"; err == nil || err.Error() != msg {
+		t.Errorf("Failed on: reserved text, expected %v, got %v", msg, err)
+	}
+}
@@ -0,0 +1,290 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// genArrayOperations generates the operations between two arrays whose
+// elements are matched by arrOpts.Diff rather than by index. Matched elements
+// are diffed recursively at their new index, and elements whose order relative
+// to the other matched elements changed are moved first with a MOVE operation.
+// Elements of the old array that are not matched are deleted, and elements of
+// the new array that are not matched are created, in the order set by
+// opts.Order. Since deleted elements have no index in the new array, the path of
+// a DELETE operation is the array, and From is the old index of the element.
+func genArrayOperations(oldArr v.Array, newArr v.Array, q *OpQueue, prefix v.Path, arrOpts ArrayOptions, opts *Options) {
+	var match []int
+	ok := false
+
+	if arrOpts.Diff == KeyDiff {
+		match, ok = matchByKey(oldArr, newArr, arrOpts.Key)
+	}
+
+	if !ok {
+		match = matchByLCS(oldArr, newArr)
+	}
+
+	moved := movedElements(match)
+	matched := make([]bool, len(oldArr))
+
+	for i, j := range match {
+		if j < 0 {
+			continue
+		}
+
+		matched[j] = true
+
+		if moved[i] {
+			q.Enqueue(&Operation{
+				Op:   MOVE,
+				Path: prefix.Index(i),
+				Old:  oldArr[j],
+				New:  newArr[i],
+				From: prefix.Index(j)})
+		}
+
+		genOperationRecursive(oldArr[j], newArr[i], q, prefix.Index(i), opts)
+	}
+
+	// all old unmatched elements become DELETE operations
+	deletes := func() {
+		for j, ok := range matched {
+			if !ok {
+				q.Enqueue(&Operation{
+					Op:   DELETE,
+					Path: prefix,
+					Old:  oldArr[j],
+					New:  nil,
+					From: prefix.Index(j)})
+			}
+		}
+	}
+
+	// all new unmatched elements become CREATE operations
+	creates := func() {
+		for i, j := range match {
+			if j < 0 {
+				q.Enqueue(&Operation{
+					Op:   CREATE,
+					Path: prefix.Index(i),
+					Old:  nil,
+					New:  newArr[i]})
+			}
+		}
+	}
+
+	opts.Order.enqueue(deletes, creates)
+}
+
+// matchByKey matches the objects of two arrays that have the same value for a
+// key field. match[i] is the index of the old element matched to newArr[i], or
+// -1 if newArr[i] is not matched. It returns false if an element is not an
+// object, or has no value or a duplicate value for the key.
+func matchByKey(oldArr v.Array, newArr v.Array, key string) (match []int, ok bool) {
+	oldIndex, ok := indexByKey(oldArr, key)
+
+	if !ok {
+		return nil, false
+	}
+
+	newIndex, ok := indexByKey(newArr, key)
+
+	if !ok {
+		return nil, false
+	}
+
+	match = make([]int, len(newArr))
+
+	for i := range newArr {
+		match[i] = -1
+	}
+
+	for id, i := range newIndex {
+		if j, found := oldIndex[id]; found {
+			match[i] = j
+		}
+	}
+
+	return match, true
+}
+
+// indexByKey returns the index of each element of an array by its value for a
+// key field
+func indexByKey(arr v.Array, key string) (map[string]int, bool) {
+	index := make(map[string]int)
+
+	for i, elem := range arr {
+		obj, ok := elem.(v.Object)
+
+		if !ok {
+			return nil, false
+		}
+
+		val, found := obj[v.String(key)]
+
+		if !found || val == nil {
+			return nil, false
+		}
+
+		str, err := v.Stringify(val)
+
+		if err != nil {
+			return nil, false
+		}
+
+		// keep values of different types apart, e.g. "1" and 1
+		id := fmt.Sprintf("%T:%s", val, str)
+
+		if _, found := index[id]; found {
+			return nil, false
+		}
+
+		index[id] = i
+	}
+
+	return index, true
+}
+
+// matchByLCS matches the equal elements of two arrays that form their longest
+// common subsequence. The remaining equal elements are matched as well, even if
+// their order changed, and the remaining elements between two elements of the
+// subsequence are matched in order. match[i] is the index of the old element
+// matched to newArr[i], or -1 if newArr[i] is not matched.
+func matchByLCS(oldArr v.Array, newArr v.Array) []int {
+	n, m := len(oldArr), len(newArr)
+
+	// lcs[j][i] is the length of the longest common subsequence of oldArr[j:]
+	// and newArr[i:]
+	lcs := make([][]int, n+1)
+
+	for j := range lcs {
+		lcs[j] = make([]int, m+1)
+	}
+
+	for j := n - 1; j >= 0; j-- {
+		for i := m - 1; i >= 0; i-- {
+			if v.IsEqual(oldArr[j], newArr[i]) {
+				lcs[j][i] = lcs[j+1][i+1] + 1
+			} else if lcs[j+1][i] >= lcs[j][i+1] {
+				lcs[j][i] = lcs[j+1][i]
+			} else {
+				lcs[j][i] = lcs[j][i+1]
+			}
+		}
+	}
+
+	match := make([]int, m)
+	matched := make([]bool, n)
+
+	for i := range match {
+		match[i] = -1
+	}
+
+	// walk the subsequence, recording the elements between each of its
+	// elements as a gap
+	type gap struct{ oldStart, oldEnd, newStart, newEnd int }
+
+	var gaps []gap
+	last := gap{}
+
+	for j, i := 0, 0; j < n && i < m; {
+		if v.IsEqual(oldArr[j], newArr[i]) && lcs[j][i] == lcs[j+1][i+1]+1 {
+			gaps = append(gaps, gap{last.oldStart, j, last.newStart, i})
+			last = gap{oldStart: j + 1, newStart: i + 1}
+
+			match[i] = j
+			matched[j] = true
+			j++
+			i++
+		} else if lcs[j+1][i] >= lcs[j][i+1] {
+			j++
+		} else {
+			i++
+		}
+	}
+
+	gaps = append(gaps, gap{last.oldStart, n, last.newStart, m})
+
+	// match the remaining equal elements, which were reordered
+	for i := range newArr {
+		for j := range oldArr {
+			if match[i] < 0 && !matched[j] && v.IsEqual(oldArr[j], newArr[i]) {
+				match[i] = j
+				matched[j] = true
+			}
+		}
+	}
+
+	// match the remaining elements of each gap in order, so that they are
+	// updated rather than deleted and created
+	for _, g := range gaps {
+		j := g.oldStart
+
+		for i := g.newStart; i < g.newEnd; i++ {
+			if match[i] >= 0 {
+				continue
+			}
+
+			for j < g.oldEnd && matched[j] {
+				j++
+			}
+
+			if j == g.oldEnd {
+				break
+			}
+
+			match[i] = j
+			matched[j] = true
+		}
+	}
+
+	return match
+}
+
+// movedElements returns, for each element of the new array, whether it has to
+// be moved: the matched elements that are not part of the longest subsequence
+// of matched elements that kept their relative order are moved.
+func movedElements(match []int) []bool {
+	moved := make([]bool, len(match))
+
+	// tails[k] is the index in match of the smallest tail of an increasing
+	// subsequence of length k+1, and prev links each element to the previous
+	// element of its subsequence
+	var tails []int
+	prev := make([]int, len(match))
+
+	for i, j := range match {
+		if j < 0 {
+			continue
+		}
+
+		moved[i] = true
+
+		k := sort.Search(len(tails), func(k int) bool {
+			return match[tails[k]] >= j
+		})
+
+		if k > 0 {
+			prev[i] = tails[k-1]
+		} else {
+			prev[i] = -1
+		}
+
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			moved[i] = false
+		}
+	}
+
+	return moved
+}
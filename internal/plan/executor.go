@@ -0,0 +1,425 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type (
+	// OpError is the error returned by the run function of Execute for an
+	// operation
+	OpError struct {
+		Op  *Operation
+		Err error
+	}
+
+	// ExecError is returned by Execute when operations fail, or when the
+	// context is done before every operation ran. Failed holds the error of each
+	// operation that failed, and Skipped the operations that were not run,
+	// either because they depend on an operation that failed or because they
+	// had not started when the context was done. Interrupted is the error of
+	// the context in the latter case.
+	ExecError struct {
+		Failed      []OpError
+		Skipped     []*Operation
+		Interrupted error
+	}
+
+	// PanicError is the error of an operation whose run function panicked.
+	// Value is the value passed to panic, and Stack the stack trace of the
+	// goroutine that panicked.
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// execNode is a node of the dependency graph built by Execute: the queue of
+	// operations at a path, which run in order
+	execNode struct {
+		tree  *TreeNode
+		first int // index of the first operation of the node in the plan
+
+		dependents []*execNode
+		pending    int  // number of nodes that must finish before the node runs
+		blocked    bool // a node that the node depends on failed
+	}
+
+	// execResult is the result of running the operations of a node
+	execResult struct {
+		node        *execNode
+		failed      *OpError
+		skipped     []*Operation
+		interrupted bool
+	}
+)
+
+// Error lists the operations that failed.
+func (e *ExecError) Error() string {
+	var msgs []string
+
+	for _, f := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", f.Op.ToString(), f.Err))
+	}
+
+	if e.Interrupted != nil {
+		msgs = append(msgs, fmt.Sprintf("interrupted: %v", e.Interrupted))
+	}
+
+	res := strings.Join(msgs, "; ")
+
+	if len(e.Skipped) > 0 {
+		res += fmt.Sprintf(" (%d operations skipped)", len(e.Skipped))
+	}
+
+	return res
+}
+
+// Error returns the value passed to panic.
+func (e *PanicError) Error() string {
+	return fmt.Sprint(e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+
+	return err
+}
+
+// Unwrap returns the error of the first operation that failed, or the error of
+// the context if no operation failed, so that errors.Is and errors.As match the
+// errors returned by run and context.Canceled.
+func (e *ExecError) Unwrap() error {
+	if len(e.Failed) == 0 {
+		return e.Interrupted
+	}
+
+	return e.Failed[0].Err
+}
+
+// Execute calls run for each operation of a plan, running independent
+// operations concurrently. The operations are organized into a tree by path
+// (see NewPathTree): the operations at a path run in queue order, after the
+// operations at its ancestor paths, and after the operations that it depends on
+// through opts.DependsOn. The DELETE and CREATE operations of the keys of an
+// object or array run in queue order, so that Options.Order holds. At most
+// opts.Parallelism operations run at the same time, and run must be safe to call
+// concurrently if it is greater than 1.
+//
+// When an operation fails, the remaining operations at its path and every
+// operation that depends on it are skipped, while independent operations still
+// run. An operation whose run function panics fails with a *PanicError. When ctx is done, the operations that are running finish, but no other
+// operation starts. In both cases, Execute returns an *ExecError. The queue is
+// not modified.
+//
+// The context passed to run is cancelled when ctx is, or when opts.Timeout
+// expires.
+func Execute(ctx context.Context, q *OpQueue, run func(ctx context.Context, op *Operation) error, opts ExecOptions) error {
+	nodes, err := buildGraph(q, opts)
+
+	if err != nil {
+		return err
+	}
+
+	parallelism := opts.Parallelism
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var ready []*execNode
+
+	for _, node := range nodes {
+		if node.pending == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	execErr := &ExecError{}
+	results := make(chan execResult)
+	running := 0
+
+	// finish records that a node is done, and schedules the dependents that no
+	// longer wait for any node
+	finish := func(node *execNode, failed bool) {
+		for _, dep := range node.dependents {
+			dep.pending--
+			dep.blocked = dep.blocked || failed
+
+			if dep.pending == 0 {
+				ready = append(ready, dep)
+			}
+		}
+
+		// run the nodes in plan order when possible
+		sort.SliceStable(ready, func(i, j int) bool {
+			return ready[i].first < ready[j].first
+		})
+	}
+
+	for len(ready) > 0 || running > 0 {
+		for running < parallelism && len(ready) > 0 {
+			node := ready[0]
+			ready = ready[1:]
+
+			if node.blocked || ctx.Err() != nil {
+				if !node.blocked {
+					execErr.Interrupted = ctx.Err()
+				}
+
+				execErr.Skipped = append(execErr.Skipped, node.tree.val.Ops()...)
+				finish(node, true)
+
+				continue
+			}
+
+			running++
+
+			go func(node *execNode) {
+				results <- runNode(ctx, node, run, opts.Timeout)
+			}(node)
+		}
+
+		if running == 0 {
+			continue
+		}
+
+		res := <-results
+		running--
+
+		if res.failed != nil {
+			execErr.Failed = append(execErr.Failed, *res.failed)
+		}
+
+		if res.interrupted {
+			execErr.Interrupted = ctx.Err()
+		}
+
+		execErr.Skipped = append(execErr.Skipped, res.skipped...)
+
+		finish(res.node, res.failed != nil || res.interrupted)
+	}
+
+	if len(execErr.Failed) > 0 || execErr.Interrupted != nil {
+		return execErr
+	}
+
+	return nil
+}
+
+// runNode runs the operations of a node in order, until one of them fails or
+// ctx is done
+func runNode(ctx context.Context, node *execNode, run func(ctx context.Context, op *Operation) error, timeout time.Duration) execResult {
+	ops := node.tree.val.Ops()
+
+	for i, op := range ops {
+		if ctx.Err() != nil {
+			return execResult{
+				node:        node,
+				skipped:     ops[i:],
+				interrupted: true,
+			}
+		}
+
+		if err := runOp(ctx, op, run, timeout); err != nil {
+			return execResult{
+				node:    node,
+				failed:  &OpError{Op: op, Err: err},
+				skipped: ops[i+1:],
+			}
+		}
+	}
+
+	return execResult{node: node}
+}
+
+// runOp runs an operation with a context that expires after timeout, if it is
+// greater than 0, and returns a panic as a *PanicError
+func runOp(ctx context.Context, op *Operation, run func(ctx context.Context, op *Operation) error, timeout time.Duration) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return run(ctx, op)
+}
+
+// buildGraph builds the dependency graph of the operations of a plan, and
+// returns its nodes in plan order. It returns an error if the dependencies
+// declared in opts form a cycle.
+func buildGraph(q *OpQueue, opts ExecOptions) ([]*execNode, error) {
+	tree := NewPathTree(q)
+
+	index := make(map[*Operation]int)
+
+	for i, op := range q.Ops() {
+		index[op] = i
+	}
+
+	var nodes []*execNode
+	byTree := make(map[*TreeNode]*execNode)
+
+	var add func(tree *TreeNode, parent *execNode)
+
+	add = func(tree *TreeNode, parent *execNode) {
+		node := &execNode{tree: tree, first: len(index)}
+
+		if op := tree.val.Peek(); op != nil {
+			node.first = index[op]
+		}
+
+		nodes = append(nodes, node)
+		byTree[tree] = node
+
+		if parent != nil {
+			parent.dependents = append(parent.dependents, node)
+			node.pending++
+		}
+
+		for _, child := range tree.children {
+			add(child, node)
+		}
+	}
+
+	add(tree.Root, nil)
+
+	// add the declared dependencies between the nodes at or under the paths
+	for path, deps := range opts.DependsOn {
+		p, err := v.ParsePath(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dep := range deps {
+			d, err := v.ParsePath(dep)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, node := range nodes {
+				if !node.tree.path.HasPrefix(p) {
+					continue
+				}
+
+				for _, other := range nodes {
+					if other != node && other.tree.path.HasPrefix(d) {
+						other.dependents = append(other.dependents, node)
+						node.pending++
+					}
+				}
+			}
+		}
+	}
+
+	if hasCycle(nodes) {
+		return nil, errors.New("Dependencies between operations form a cycle")
+	}
+
+	// the deleted and created keys of an object or array run in plan order, see
+	// Options.Order, unless the declared dependencies order them the other way
+	for _, node := range nodes {
+		for _, a := range node.tree.children {
+			for _, b := range node.tree.children {
+				first, then := byTree[a], byTree[b]
+
+				if first.first < then.first && isDeleteOrCreate(first, then) && !reaches(then, first) {
+					first.dependents = append(first.dependents, then)
+					then.pending++
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].first < nodes[j].first
+	})
+
+	return nodes, nil
+}
+
+// isDeleteOrCreate returns true if one of two nodes deletes the value at its
+// path, and the other creates the value at its path
+func isDeleteOrCreate(a *execNode, b *execNode) bool {
+	opA, opB := a.tree.val.Peek(), b.tree.val.Peek()
+
+	if opA == nil || opB == nil {
+		return false
+	}
+
+	return (opA.Op == DELETE && opB.Op == CREATE) || (opA.Op == CREATE && opB.Op == DELETE)
+}
+
+// reaches returns true if a node runs before another node through the
+// dependency graph
+func reaches(from *execNode, to *execNode) bool {
+	seen := make(map[*execNode]bool)
+	stack := []*execNode{from}
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if node == to {
+			return true
+		}
+
+		if seen[node] {
+			continue
+		}
+
+		seen[node] = true
+		stack = append(stack, node.dependents...)
+	}
+
+	return false
+}
+
+// hasCycle returns true if the dependency graph has a cycle, i.e. if some nodes
+// can never run
+func hasCycle(nodes []*execNode) bool {
+	pending := make(map[*execNode]int)
+	var ready []*execNode
+
+	for _, node := range nodes {
+		pending[node] = node.pending
+
+		if node.pending == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	done := 0
+
+	for len(ready) > 0 {
+		node := ready[0]
+		ready = ready[1:]
+		done++
+
+		for _, dep := range node.dependents {
+			pending[dep]--
+
+			if pending[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+
+	return done != len(nodes)
+}
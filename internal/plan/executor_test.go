@@ -0,0 +1,366 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// initExecQueue is a helper method to create an OpQueue with a CREATE operation
+// at each path.
+func initExecQueue(paths ...string) *OpQueue {
+	q := NewOpQueue()
+
+	for _, path := range paths {
+		q.Enqueue(&Operation{
+			Op:   CREATE,
+			Path: v.MustParsePath(path),
+			New:  v.String(path),
+		})
+	}
+
+	return q
+}
+
+// recorder records the paths of the operations that ran, in order.
+type recorder struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *recorder) run(ctx context.Context, op *Operation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.paths = append(r.paths, op.Path.String())
+
+	return nil
+}
+
+func (r *recorder) index(path string) int {
+	for i, p := range r.paths {
+		if p == path {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestExecuteOrder(t *testing.T) {
+	q := initExecQueue("[b]", "[ns][a]", "[ns]", "[ns][a][x]")
+	r := &recorder{}
+
+	err := Execute(context.Background(), q, r.run, ExecOptions{
+		Parallelism: 4,
+		DependsOn: map[string][]string{
+			"[b]": []string{"[ns][a]"},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.paths) != 4 {
+		t.Fatalf("Expected 4 operations to run, got %v", r.paths)
+	}
+
+	order := [][2]string{
+		{"[ns]", "[ns][a]"},
+		{"[ns][a]", "[ns][a][x]"},
+		{"[ns][a]", "[b]"},
+	}
+
+	for _, o := range order {
+		if r.index(o[0]) > r.index(o[1]) {
+			t.Errorf("Expected %s to run before %s, ran %v", o[0], o[1], r.paths)
+		}
+	}
+
+	if q.Len() != 4 {
+		t.Errorf("Expected Execute not to modify the queue")
+	}
+}
+
+func TestExecuteSerialPlanOrder(t *testing.T) {
+	q := initExecQueue("[c]", "[a]", "[b]")
+	r := &recorder{}
+
+	if err := Execute(context.Background(), q, r.run, ExecOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"[c]", "[a]", "[b]"}
+
+	for i := range want {
+		if i >= len(r.paths) || r.paths[i] != want[i] {
+			t.Fatalf("Expected %v, ran %v", want, r.paths)
+		}
+	}
+}
+
+func TestExecuteParallelism(t *testing.T) {
+	q := initExecQueue("[a]", "[b]", "[c]", "[d]", "[e]", "[f]", "[g]", "[h]")
+
+	var curr, max int32
+
+	run := func(ctx context.Context, op *Operation) error {
+		n := atomic.AddInt32(&curr, 1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&curr, -1)
+
+		return nil
+	}
+
+	if err := Execute(context.Background(), q, run, ExecOptions{Parallelism: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if max > 3 || max < 2 {
+		t.Errorf("Expected at most 3 and at least 2 concurrent operations, got %d", max)
+	}
+}
+
+func TestExecuteFailure(t *testing.T) {
+	q := initExecQueue("[a]", "[a][x]", "[b]", "[c]")
+	r := &recorder{}
+
+	run := func(ctx context.Context, op *Operation) error {
+		if op.Path.String() == "[a]" {
+			return errors.New("failed")
+		}
+
+		return r.run(ctx, op)
+	}
+
+	err := Execute(context.Background(), q, run, ExecOptions{
+		Parallelism: 2,
+		DependsOn: map[string][]string{
+			"[c]": []string{"[a]"},
+		},
+	})
+
+	execErr, ok := err.(*ExecError)
+
+	if !ok {
+		t.Fatalf("Expected an *ExecError, got %v", err)
+	}
+
+	if len(execErr.Failed) != 1 || execErr.Failed[0].Op.Path.String() != "[a]" {
+		t.Errorf("Expected [a] to fail, got %v", execErr.Failed)
+	}
+
+	if len(execErr.Skipped) != 2 {
+		t.Errorf("Expected 2 skipped operations, got %d", len(execErr.Skipped))
+	}
+
+	if len(r.paths) != 1 || r.paths[0] != "[b]" {
+		t.Errorf("Expected only [b] to run, ran %v", r.paths)
+	}
+}
+
+func TestExecutePanic(t *testing.T) {
+	q := initExecQueue("[a]", "[b]")
+	r := &recorder{}
+
+	run := func(ctx context.Context, op *Operation) error {
+		if op.Path.String() == "[a]" {
+			var m map[string]int
+			m["a"] = 1
+		}
+
+		return r.run(ctx, op)
+	}
+
+	err := Execute(context.Background(), q, run, ExecOptions{Parallelism: 2})
+
+	var panicErr *PanicError
+
+	if !errors.As(err, &panicErr) || len(panicErr.Stack) == 0 {
+		t.Fatalf("Expected a *PanicError, got %v", err)
+	}
+
+	if len(r.paths) != 1 || r.paths[0] != "[b]" {
+		t.Errorf("Expected only [b] to run, ran %v", r.paths)
+	}
+}
+
+func TestExecuteArrayDelete(t *testing.T) {
+	name := func(n string, image string) v.Object {
+		return v.Object{v.String("name"): v.String(n), v.String("image"): v.String(image)}
+	}
+
+	old := v.Object{v.String("list"): v.Array{name("a", "1"), name("b", "1")}}
+	new := v.Object{v.String("list"): v.Array{name("b", "2")}}
+
+	q := CreateOpQueueOptions(old, new, Options{
+		Arrays: map[string]ArrayOptions{"[list]": ArrayOptions{Diff: KeyDiff, Key: "name"}},
+	})
+
+	r := &recorder{}
+
+	// the DELETE of the old element [list][0] fails
+	run := func(ctx context.Context, op *Operation) error {
+		if op.Op == DELETE {
+			return errors.New("failed")
+		}
+
+		return r.run(ctx, op)
+	}
+
+	err := Execute(context.Background(), q, run, ExecOptions{Parallelism: 1})
+
+	execErr, ok := err.(*ExecError)
+
+	if !ok || len(execErr.Failed) != 1 || !execErr.Failed[0].Op.From.Equal(v.MustParsePath("[list][0]")) {
+		t.Fatalf("Expected the DELETE of [list][0] to fail, got %v", err)
+	}
+
+	// the new element [list][0] is another element, and is still updated
+	if r.index("[list][0][image]") < 0 {
+		t.Errorf("Expected [list][0][image] to run, ran %v", r.paths)
+	}
+}
+
+func TestExecuteDeleteCreateOrder(t *testing.T) {
+	old := v.Object{v.String("svc"): v.Object{v.String("a"): v.Integer(1)}}
+	new := v.Object{v.String("svc"): v.Object{v.String("b"): v.Integer(1)}}
+
+	for _, order := range []Order{DeletesFirst, CreatesFirst} {
+		q := CreateOpQueueOptions(old, new, Options{Order: order})
+
+		var mu sync.Mutex
+		var events []string
+
+		record := func(event string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			events = append(events, event)
+		}
+
+		// the first operation is slow, so that a concurrent operation would
+		// start before it ends
+		run := func(ctx context.Context, op *Operation) error {
+			record("start " + op.Op.String())
+
+			if (op.Op == DELETE) == (order == DeletesFirst) {
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			record("end " + op.Op.String())
+
+			return nil
+		}
+
+		if err := Execute(context.Background(), q, run, ExecOptions{Parallelism: 4}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"start DELETE", "end DELETE", "start CREATE", "end CREATE"}
+
+		if order == CreatesFirst {
+			want = []string{"start CREATE", "end CREATE", "start DELETE", "end DELETE"}
+		}
+
+		if len(events) != len(want) {
+			t.Fatalf("Failed on order %d: expected %v, got %v", order, want, events)
+		}
+
+		for i := range want {
+			if events[i] != want[i] {
+				t.Errorf("Failed on order %d: expected %v, got %v", order, want, events)
+				break
+			}
+		}
+	}
+}
+
+func TestExecuteCycle(t *testing.T) {
+	q := initExecQueue("[a]", "[b]")
+	r := &recorder{}
+
+	err := Execute(context.Background(), q, r.run, ExecOptions{
+		DependsOn: map[string][]string{
+			"[a]": []string{"[b]"},
+			"[b]": []string{"[a]"},
+		},
+	})
+
+	if err == nil || len(r.paths) != 0 {
+		t.Errorf("Expected a cycle error without running operations, got %v, ran %v", err, r.paths)
+	}
+}
+
+func TestExecuteInterrupt(t *testing.T) {
+	q := initExecQueue("[a]", "[a][x]", "[b]", "[c]")
+	r := &recorder{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the operation that is running when the context is cancelled finishes
+	run := func(ctx context.Context, op *Operation) error {
+		if op.Path.String() == "[a]" {
+			cancel()
+		}
+
+		return r.run(ctx, op)
+	}
+
+	err := Execute(ctx, q, run, ExecOptions{})
+
+	execErr, ok := err.(*ExecError)
+
+	if !ok {
+		t.Fatalf("Expected an *ExecError, got %v", err)
+	}
+
+	if !errors.Is(err, context.Canceled) || len(execErr.Failed) != 0 {
+		t.Errorf("Expected an interruption without failures, got %v", err)
+	}
+
+	if len(execErr.Skipped) != 3 {
+		t.Errorf("Expected 3 skipped operations, got %d", len(execErr.Skipped))
+	}
+
+	if len(r.paths) != 1 || r.paths[0] != "[a]" {
+		t.Errorf("Expected only [a] to run, ran %v", r.paths)
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	q := initExecQueue("[a]", "[b]")
+
+	run := func(ctx context.Context, op *Operation) error {
+		if op.Path.String() == "[a]" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		return nil
+	}
+
+	err := Execute(context.Background(), q, run, ExecOptions{Timeout: 10 * time.Millisecond})
+
+	execErr, ok := err.(*ExecError)
+
+	if !ok || len(execErr.Failed) != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected [a] to time out, got %v", err)
+	}
+}
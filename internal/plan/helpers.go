@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"fmt"
 	"strconv"
 
 	v "github.com/porterdev/ego/internal/value"
@@ -15,14 +16,101 @@ func (op1 *Operation) IsEqual(op2 *Operation) bool {
 	res = res && v.IsEqual(op1.Old, op2.Old) && v.IsEqual(op1.New, op2.New)
 
 	// check paths are equal
-	res = res && op1.Path == op2.Path
+	res = res && op1.Path.Equal(op2.Path) && op1.From.Equal(op2.From)
+
+	// check replacement order is equal
+	res = res && op1.CreateBeforeDelete == op2.CreateBeforeDelete
 
 	return res
 }
 
 // ToString converts an operation to a string based on the operation type and the
 // path. For example, an UPDATE operation at the path [example] becomes
-// "2:[example]"
+// "2:[example]", and a MOVE operation from [list][1] to [list][0] becomes
+// "4:[list][0]<[list][1]"
 func (op1 *Operation) ToString() string {
-	return strconv.Itoa(int(op1.Op)) + ":" + op1.Path
+	res := strconv.Itoa(int(op1.Op)) + ":" + op1.Path.String()
+
+	if len(op1.From) > 0 {
+		res += "<" + op1.From.String()
+	}
+
+	return res
+}
+
+// String returns the name of an operation type, e.g. "CREATE"
+func (op OpType) String() string {
+	switch op {
+	case CREATE:
+		return "CREATE"
+	case READ:
+		return "READ"
+	case UPDATE:
+		return "UPDATE"
+	case DELETE:
+		return "DELETE"
+	case MOVE:
+		return "MOVE"
+	case REPLACE:
+		return "REPLACE"
+	}
+
+	return "OpType(" + strconv.Itoa(int(op)) + ")"
+}
+
+// ParseOpType returns the operation type named by String, e.g. UPDATE for
+// "UPDATE"
+func ParseOpType(name string) (OpType, error) {
+	for _, op := range []OpType{CREATE, READ, UPDATE, DELETE, MOVE, REPLACE} {
+		if op.String() == name {
+			return op, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown operation type %s", name)
+}
+
+// Inverse returns the operation that undoes an operation: a DELETE for a CREATE,
+// a CREATE for a DELETE, and an UPDATE, REPLACE or MOVE with the old and new
+// values swapped. READ operations do not change anything, so their inverse is
+// nil.
+func (op1 *Operation) Inverse() *Operation {
+	switch op1.Op {
+	case CREATE:
+		return &Operation{
+			Op:   DELETE,
+			Path: op1.Path,
+			Old:  op1.New,
+			New:  nil}
+	case DELETE:
+		path := op1.Path
+
+		// an element of an array diffed by key or LCS is created at its old
+		// index
+		if len(op1.From) > 0 {
+			path = op1.From
+		}
+
+		return &Operation{
+			Op:   CREATE,
+			Path: path,
+			Old:  nil,
+			New:  op1.Old}
+	case UPDATE, REPLACE:
+		return &Operation{
+			Op:                 op1.Op,
+			Path:               op1.Path,
+			Old:                op1.New,
+			New:                op1.Old,
+			CreateBeforeDelete: op1.CreateBeforeDelete}
+	case MOVE:
+		return &Operation{
+			Op:   MOVE,
+			Path: op1.From,
+			Old:  op1.New,
+			New:  op1.Old,
+			From: op1.Path}
+	}
+
+	return nil
 }
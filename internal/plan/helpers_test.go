@@ -11,7 +11,7 @@ func TestIsOpEqual(t *testing.T) {
 		Op:   UPDATE,
 		Old:  v.Boolean(true),
 		New:  v.Boolean(false),
-		Path: "",
+		Path: v.MustParsePath(""),
 	}
 
 	op2 := op1
@@ -28,7 +28,7 @@ func TestIsOpEqual(t *testing.T) {
 	}
 
 	op4 := op1
-	op4.Path = "Hello"
+	op4.Path = v.MustParsePath("Hello")
 
 	if op1.IsEqual(&op4) {
 		t.Errorf("Expected IsEqualOp(op1, op4) to be false, got true")
@@ -41,3 +41,93 @@ func TestIsOpEqual(t *testing.T) {
 		t.Errorf("Expected IsEqualOp(op1, op5) to be false, got true")
 	}
 }
+
+func TestOpTypeString(t *testing.T) {
+	cases := []struct {
+		in   OpType
+		want string
+	}{
+		{in: CREATE, want: "CREATE"},
+		{in: READ, want: "READ"},
+		{in: UPDATE, want: "UPDATE"},
+		{in: DELETE, want: "DELETE"},
+		{in: MOVE, want: "MOVE"},
+		{in: REPLACE, want: "REPLACE"},
+		{in: OpType(9), want: "OpType(9)"},
+	}
+
+	for _, c := range cases {
+		if got := c.in.String(); got != c.want {
+			t.Errorf("%d.String() == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseOpType(t *testing.T) {
+	for _, op := range []OpType{CREATE, READ, UPDATE, DELETE, MOVE, REPLACE} {
+		got, err := ParseOpType(op.String())
+
+		if err != nil || got != op {
+			t.Errorf("ParseOpType(%q) == %d, %v, want %d", op.String(), got, err, op)
+		}
+	}
+
+	if _, err := ParseOpType("MERGE"); err == nil {
+		t.Errorf("ParseOpType(\"MERGE\") did not return an error")
+	}
+}
+
+func TestInverse(t *testing.T) {
+	path := v.MustParsePath("[a][0]")
+	from := v.MustParsePath("[a][2]")
+
+	cases := []struct {
+		in   Operation
+		want *Operation
+	}{
+		{
+			in:   Operation{Op: CREATE, Path: path, New: v.Integer(1)},
+			want: &Operation{Op: DELETE, Path: path, Old: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: DELETE, Path: path, Old: v.Integer(1)},
+			want: &Operation{Op: CREATE, Path: path, New: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: DELETE, Path: v.MustParsePath("[a]"), From: from, Old: v.Integer(1)},
+			want: &Operation{Op: CREATE, Path: from, New: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: UPDATE, Path: path, Old: v.Integer(1), New: v.Integer(2)},
+			want: &Operation{Op: UPDATE, Path: path, Old: v.Integer(2), New: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: REPLACE, Path: path, Old: v.Integer(1), New: v.String("1")},
+			want: &Operation{Op: REPLACE, Path: path, Old: v.String("1"), New: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: MOVE, Path: path, From: from, Old: v.Integer(1), New: v.Integer(1)},
+			want: &Operation{Op: MOVE, Path: from, From: path, Old: v.Integer(1), New: v.Integer(1)},
+		},
+		{
+			in:   Operation{Op: READ, Path: path, Old: v.Integer(1), New: v.Integer(1)},
+			want: nil,
+		},
+	}
+
+	for _, c := range cases {
+		got := c.in.Inverse()
+
+		if c.want == nil {
+			if got != nil {
+				t.Errorf("%s.Inverse() == %s, want nil", c.in.ToString(), got.ToString())
+			}
+
+			continue
+		}
+
+		if got == nil || !got.IsEqual(c.want) {
+			t.Errorf("%s.Inverse() == %v, want %s", c.in.ToString(), got, c.want.ToString())
+		}
+	}
+}
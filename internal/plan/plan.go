@@ -2,7 +2,7 @@ package plan
 
 import (
 	"math"
-	"strconv"
+	"sort"
 
 	v "github.com/porterdev/ego/internal/value"
 )
@@ -10,11 +10,54 @@ import (
 // CreateOpQueue takes the current config (old) and generates a sequence of operations
 // to create the desired config (new).
 func CreateOpQueue(old v.Value, new v.Value, paths ...string) *OpQueue {
+	return CreateOpQueueOptions(old, new, Options{Resources: paths})
+}
+
+// CreateOpQueueOptions is CreateOpQueue, with options that configure how
+// resources and arrays are diffed.
+func CreateOpQueueOptions(old v.Value, new v.Value, opts Options) *OpQueue {
 	q := NewOpQueue()
-	genOperationRecursive(old, new, q, "", paths...)
+	opts = opts.normalize()
+	genOperationRecursive(old, new, q, v.Path{}, &opts)
 	return q
 }
 
+// normalize returns a copy of the options with every path formatted by
+// value.Path.String, so that paths can be compared as strings. Paths that cannot
+// be parsed are kept as is, and never match.
+func (opts Options) normalize() Options {
+	normalizeAll := func(paths []string) []string {
+		res := make([]string, len(paths))
+
+		for i, path := range paths {
+			res[i] = normalizePath(path)
+		}
+
+		return res
+	}
+
+	res := opts
+	res.Resources = normalizeAll(opts.Resources)
+	res.ForceNew = normalizeAll(opts.ForceNew)
+	res.CreateBeforeDelete = normalizeAll(opts.CreateBeforeDelete)
+	res.Arrays = make(map[string]ArrayOptions)
+
+	for path, arrOpts := range opts.Arrays {
+		res.Arrays[normalizePath(path)] = arrOpts
+	}
+
+	return res
+}
+
+// normalizePath formats a path by value.Path.String
+func normalizePath(path string) string {
+	if p, err := v.ParsePath(path); err == nil {
+		return p.String()
+	}
+
+	return path
+}
+
 func contains(paths []string, path string) bool {
 	for _, _path := range paths {
 		if _path == path {
@@ -25,7 +68,7 @@ func contains(paths []string, path string) bool {
 	return false
 }
 
-func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string, paths ...string) {
+func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix v.Path, opts *Options) {
 	if old == nil && new == nil {
 		return
 	} else if old == nil && new != nil {
@@ -44,7 +87,17 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 		return
 	}
 
-	if contains(paths, prefix) {
+	if opts.forcesNew(old, new, prefix) {
+		enqueueReplaceOp(old, new, q, prefix, opts)
+		return
+	}
+
+	if contains(opts.Resources, prefix.String()) {
+		if !sameKind(old, new) {
+			enqueueReplaceOp(old, new, q, prefix, opts)
+			return
+		}
+
 		if !v.IsEqual(old, new) {
 			q.Enqueue(&Operation{
 				Op:   UPDATE,
@@ -67,16 +120,16 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 	switch old.(type) {
 	case v.Boolean:
 		_, ok := new.(v.Boolean)
-		enqueuePrimitiveOp(ok, old, new, q, prefix)
+		enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
 	case v.Float:
 		_, ok := new.(v.Float)
-		enqueuePrimitiveOp(ok, old, new, q, prefix)
+		enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
 	case v.Integer:
 		_, ok := new.(v.Integer)
-		enqueuePrimitiveOp(ok, old, new, q, prefix)
+		enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
 	case v.String:
 		_, ok := new.(v.String)
-		enqueuePrimitiveOp(ok, old, new, q, prefix)
+		enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
 	case v.Array:
 		oldArr, _ := old.(v.Array)
 		newArr, ok := new.(v.Array)
@@ -84,7 +137,12 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 		if !ok {
 			// If types are different, can treat this as a "primitive" operation that just
 			// gets written as an UPDATE operation.
-			enqueuePrimitiveOp(ok, old, new, q, prefix)
+			enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
+			return
+		}
+
+		if arrOpts, found := opts.Arrays[prefix.String()]; found && arrOpts.Diff != IndexDiff {
+			genArrayOperations(oldArr, newArr, q, prefix, arrOpts, opts)
 			return
 		}
 
@@ -94,7 +152,7 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 		// iterate through all shared indices, which get queued as UPDATE operations
 		for i := 0.; i < math.Min(float64(oldLen), float64(newLen)); i++ {
 			_i := int(i)
-			genOperationRecursive(oldArr[_i], newArr[_i], q, prefix+"["+strconv.Itoa(_i)+"]", paths...)
+			genOperationRecursive(oldArr[_i], newArr[_i], q, prefix.Index(_i), opts)
 		}
 
 		if oldLen > newLen {
@@ -102,7 +160,7 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 			for i := newLen; i < oldLen; i++ {
 				q.Enqueue(&Operation{
 					Op:   DELETE,
-					Path: prefix + "[" + strconv.Itoa(i) + "]",
+					Path: prefix.Index(i),
 					Old:  oldArr[i],
 					New:  nil})
 			}
@@ -111,7 +169,7 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 			for i := oldLen; i < newLen; i++ {
 				q.Enqueue(&Operation{
 					Op:   CREATE,
-					Path: prefix + "[" + strconv.Itoa(i) + "]",
+					Path: prefix.Index(i),
 					Old:  nil,
 					New:  newArr[i]})
 			}
@@ -123,57 +181,68 @@ func genOperationRecursive(old v.Value, new v.Value, q *OpQueue, prefix string,
 		if !ok {
 			// If types are different, can treat this as a "primitive" operation that just
 			// gets written as an UPDATE operation.
-			enqueuePrimitiveOp(ok, old, new, q, prefix)
+			enqueuePrimitiveOp(ok, old, new, q, prefix, opts)
 			return
 		}
 
-		// iterate through keys to discover which keys are shared, and recurse on each
-		// shared key
-		oldKeys := make(map[v.String]int)
-		newKeys := make(map[v.String]int)
+		// iterate through keys in sorted order to discover which keys are shared,
+		// deleted and created, so that the operations are enqueued in a stable order
+		var shared, deleted, created []v.String
 
-		// add each newObj key to newKeys
-		for k := range newObj {
-			newKeys[k] = 0
+		for _, k := range sortedKeys(oldObj) {
+			if _, found := newObj[k]; found {
+				shared = append(shared, k)
+			} else {
+				deleted = append(deleted, k)
+			}
 		}
 
-		for k := range oldObj {
-			// determine if the key exists in the new object
-			if _, found := newKeys[k]; found {
-				// remove from newKeys
-				delete(newKeys, k)
-
-				// recurse with both child objects
-				genOperationRecursive(oldObj[k], newObj[k], q, prefix+"["+string(k)+"]", paths...)
-			} else {
-				// add to oldKeys
-				oldKeys[k] = 0
+		for _, k := range sortedKeys(newObj) {
+			if _, found := oldObj[k]; !found {
+				created = append(created, k)
 			}
 		}
 
+		// recurse with both child objects of each shared key
+		for _, k := range shared {
+			genOperationRecursive(oldObj[k], newObj[k], q, prefix.Key(string(k)), opts)
+		}
+
 		// all old unshared keys become DELETE operations
-		for k := range oldKeys {
-			q.Enqueue(&Operation{
-				Op:   DELETE,
-				Path: prefix + "[" + string(k) + "]",
-				Old:  oldObj[k],
-				New:  nil})
+		deletes := func() {
+			for _, k := range deleted {
+				q.Enqueue(&Operation{
+					Op:   DELETE,
+					Path: prefix.Key(string(k)),
+					Old:  oldObj[k],
+					New:  nil})
+			}
 		}
 
 		// all new unshared keys become CREATE operations
-		for k := range newKeys {
-			q.Enqueue(&Operation{
-				Op:   CREATE,
-				Path: prefix + "[" + string(k) + "]",
-				Old:  nil,
-				New:  newObj[k]})
+		creates := func() {
+			for _, k := range created {
+				q.Enqueue(&Operation{
+					Op:   CREATE,
+					Path: prefix.Key(string(k)),
+					Old:  nil,
+					New:  newObj[k]})
+			}
 		}
+
+		opts.Order.enqueue(deletes, creates)
 	}
 
 	return
 }
 
-func enqueuePrimitiveOp(ok bool, old v.Value, new v.Value, q *OpQueue, prefix string) {
+func enqueuePrimitiveOp(ok bool, old v.Value, new v.Value, q *OpQueue, prefix v.Path, opts *Options) {
+	// a value that changes type cannot be updated in place, except for numbers
+	if !ok && !sameKind(old, new) {
+		enqueueReplaceOp(old, new, q, prefix, opts)
+		return
+	}
+
 	if !ok || !v.IsEqual(old, new) {
 		q.Enqueue(&Operation{
 			Op:   UPDATE,
@@ -190,3 +259,40 @@ func enqueuePrimitiveOp(ok bool, old v.Value, new v.Value, q *OpQueue, prefix st
 		New:  new})
 	return
 }
+
+func enqueueReplaceOp(old v.Value, new v.Value, q *OpQueue, prefix v.Path, opts *Options) {
+	q.Enqueue(&Operation{
+		Op:                 REPLACE,
+		Path:               prefix,
+		Old:                old,
+		New:                new,
+		CreateBeforeDelete: contains(opts.CreateBeforeDelete, prefix.String())})
+}
+
+// sortedKeys returns the keys of an object in sorted order
+func sortedKeys(obj v.Object) []v.String {
+	keys := make([]v.String, 0, len(obj))
+
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// enqueue calls deletes and creates, which enqueue the DELETE and CREATE
+// operations of an object or array, in order
+func (o Order) enqueue(deletes func(), creates func()) {
+	if o == CreatesFirst {
+		creates()
+		deletes()
+		return
+	}
+
+	deletes()
+	creates()
+}
@@ -7,10 +7,9 @@ import (
 )
 
 // Dequeues a Queue until empty to determine if it contains the same operations
-// as specified in an array of operations. Since the order of some operations within
-// a queue may change (due to hashmap randomness in Go's native "map" object), this
-// function extracts the array operations into an auxiliary hashmap, and looks up
-// the operations that are dequeued.
+// as specified in an array of operations, regardless of their order. This function
+// extracts the array operations into an auxiliary hashmap, and looks up the
+// operations that are dequeued. See TestOperationOrder for the order itself.
 //
 // This function is only meant to be used within a testing sequence, so it also logs
 // operations that are not found using the (*testing.T).Errorf method.
@@ -57,7 +56,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Boolean(true),
 				New:  v.Boolean(false),
 			},
@@ -69,7 +68,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Boolean(true),
 				New:  v.Boolean(true),
 			},
@@ -81,7 +80,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Boolean(true),
 				New:  nil,
 			},
@@ -93,7 +92,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   CREATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  nil,
 				New:  v.Boolean(true),
 			},
@@ -105,7 +104,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.String("hello"),
 				New:  v.String("there"),
 			},
@@ -117,7 +116,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.String("hello"),
 				New:  v.String("hello"),
 			},
@@ -129,7 +128,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.String("hello"),
 				New:  nil,
 			},
@@ -141,7 +140,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   CREATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  nil,
 				New:  v.String("there"),
 			},
@@ -153,7 +152,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Float(1.1),
 				New:  v.Float(1.2),
 			},
@@ -165,7 +164,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Float(1.1),
 				New:  v.Float(1.1),
 			},
@@ -177,7 +176,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Float(1.1),
 				New:  nil,
 			},
@@ -189,7 +188,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   CREATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  nil,
 				New:  v.Float(1.2),
 			},
@@ -201,7 +200,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Integer(1),
 				New:  v.Integer(2),
 			},
@@ -213,7 +212,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Integer(1),
 				New:  v.Integer(1),
 			},
@@ -225,7 +224,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Integer(1),
 				New:  nil,
 			},
@@ -237,7 +236,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   CREATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  nil,
 				New:  v.Integer(1),
 			},
@@ -249,7 +248,7 @@ var simpleLiteralPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old:  v.Float(1.1),
 				New:  v.Integer(1),
 			},
@@ -274,7 +273,7 @@ var arrayPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   CREATE,
-				Path: "[0]",
+				Path: v.MustParsePath("[0]"),
 				Old:  nil,
 				New:  v.Integer(0),
 			},
@@ -288,7 +287,7 @@ var arrayPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "[0]",
+				Path: v.MustParsePath("[0]"),
 				Old:  v.Integer(0),
 				New:  nil,
 			},
@@ -302,7 +301,7 @@ var arrayPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   DELETE,
-				Path: "[0]",
+				Path: v.MustParsePath("[0]"),
 				Old:  v.Integer(0),
 				New:  nil,
 			},
@@ -318,7 +317,7 @@ var arrayPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "[0]",
+				Path: v.MustParsePath("[0]"),
 				Old:  v.Integer(0),
 				New:  v.Integer(1),
 			},
@@ -337,19 +336,19 @@ var arrayPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "[0]",
+				Path: v.MustParsePath("[0]"),
 				Old:  v.Integer(1),
 				New:  v.Integer(1),
 			},
 			Operation{
 				Op:   READ,
-				Path: "[1]",
+				Path: v.MustParsePath("[1]"),
 				Old:  v.Integer(2),
 				New:  v.Integer(2),
 			},
 			Operation{
 				Op:   CREATE,
-				Path: "[2]",
+				Path: v.MustParsePath("[2]"),
 				Old:  nil,
 				New:  v.Integer(3),
 			},
@@ -362,8 +361,8 @@ var arrayPlanTests = []planTest{
 		new: v.String("hello"),
 		operations: []Operation{
 			Operation{
-				Op:   UPDATE,
-				Path: "",
+				Op:   REPLACE,
+				Path: v.MustParsePath(""),
 				Old: v.Array{
 					v.Integer(0),
 				},
@@ -392,7 +391,7 @@ var objectPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "[hello]",
+				Path: v.MustParsePath("[hello]"),
 				Old:  v.String("there1"),
 				New:  v.String("there2"),
 			},
@@ -409,13 +408,13 @@ var objectPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "[hello]",
+				Path: v.MustParsePath("[hello]"),
 				Old:  v.String("there"),
 				New:  v.String("there"),
 			},
 			Operation{
 				Op:   DELETE,
-				Path: "[beep]",
+				Path: v.MustParsePath("[beep]"),
 				Old:  v.String("boop"),
 				New:  nil,
 			},
@@ -433,19 +432,19 @@ var objectPlanTests = []planTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "[hello]",
+				Path: v.MustParsePath("[hello]"),
 				Old:  v.String("there1"),
 				New:  v.String("there2"),
 			},
 			Operation{
 				Op:   DELETE,
-				Path: "[beep1]",
+				Path: v.MustParsePath("[beep1]"),
 				Old:  v.String("boop1"),
 				New:  nil,
 			},
 			Operation{
 				Op:   CREATE,
-				Path: "[beep2]",
+				Path: v.MustParsePath("[beep2]"),
 				Old:  nil,
 				New:  v.String("boop2"),
 			},
@@ -458,8 +457,8 @@ var objectPlanTests = []planTest{
 		new: v.String("hello"),
 		operations: []Operation{
 			Operation{
-				Op:   UPDATE,
-				Path: "",
+				Op:   REPLACE,
+				Path: v.MustParsePath(""),
 				Old: v.Object{
 					v.String("hello"): v.String("there1"),
 				},
@@ -562,73 +561,73 @@ var k8sDeployment = planTest{
 	operations: []Operation{
 		Operation{
 			Op:   UPDATE,
-			Path: "[apiVersion]",
+			Path: v.MustParsePath("[apiVersion]"),
 			Old:  v.String("apps/v1"),
 			New:  v.String("apps/v1beta1"),
 		},
 		Operation{
 			Op:   READ,
-			Path: "[kind]",
+			Path: v.MustParsePath("[kind]"),
 			Old:  v.String("Deployment"),
 			New:  v.String("Deployment"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[metadata][name]",
+			Path: v.MustParsePath("[metadata][name]"),
 			Old:  v.String("nginx-deployment"),
 			New:  v.String("nginx2-deployment"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[metadata][labels][app]",
+			Path: v.MustParsePath("[metadata][labels][app]"),
 			Old:  v.String("nginx"),
 			New:  v.String("nginx2"),
 		},
 		Operation{
 			Op:   CREATE,
-			Path: "[metadata][namespace]",
+			Path: v.MustParsePath("[metadata][namespace]"),
 			Old:  nil,
 			New:  v.String("nginx"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][replicas]",
+			Path: v.MustParsePath("[spec][replicas]"),
 			Old:  v.Integer(3),
 			New:  v.Integer(5),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][selector][matchLabels][app]",
+			Path: v.MustParsePath("[spec][selector][matchLabels][app]"),
 			Old:  v.String("nginx"),
 			New:  v.String("nginx2"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][template][metadata][labels][app]",
+			Path: v.MustParsePath("[spec][template][metadata][labels][app]"),
 			Old:  v.String("nginx"),
 			New:  v.String("nginx2"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][template][spec][containers][0][name]",
+			Path: v.MustParsePath("[spec][template][spec][containers][0][name]"),
 			Old:  v.String("nginx"),
 			New:  v.String("nginx2"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][template][spec][containers][0][image]",
+			Path: v.MustParsePath("[spec][template][spec][containers][0][image]"),
 			Old:  v.String("nginx:1.14.2"),
 			New:  v.String("nginx2:1.14.2"),
 		},
 		Operation{
 			Op:   UPDATE,
-			Path: "[spec][template][spec][containers][0][ports][0][containerPort]",
+			Path: v.MustParsePath("[spec][template][spec][containers][0][ports][0][containerPort]"),
 			Old:  v.Integer(80),
 			New:  v.Integer(3000),
 		},
 		Operation{
 			Op:   CREATE,
-			Path: "[spec][template][spec][containers][0][ports][1]",
+			Path: v.MustParsePath("[spec][template][spec][containers][0][ports][1]"),
 			Old:  nil,
 			New: v.Object{
 				v.String("containerPort"): v.Integer(8000),
@@ -668,7 +667,7 @@ var resourcePathTests = []resourcePathTest{
 		operations: []Operation{
 			Operation{
 				Op:   READ,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old: v.Object{
 					v.String("hello"): v.Object{
 						v.String("there"): v.String("general"),
@@ -699,7 +698,7 @@ var resourcePathTests = []resourcePathTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "",
+				Path: v.MustParsePath(""),
 				Old: v.Object{
 					v.String("hello"): v.Object{
 						v.String("there"): v.String("general1"),
@@ -730,7 +729,7 @@ var resourcePathTests = []resourcePathTest{
 		operations: []Operation{
 			Operation{
 				Op:   UPDATE,
-				Path: "[hello]",
+				Path: v.MustParsePath("[hello]"),
 				Old: v.Object{
 					v.String("there"): v.String("general1"),
 				},
@@ -749,3 +748,309 @@ func TestResourcePaths(t *testing.T) {
 		isOpSequenceEqual(q, c.operations, t)
 	}
 }
+
+type arrayDiffTest struct {
+	name       string
+	opts       ArrayOptions
+	old        v.Array
+	new        v.Array
+	operations []Operation
+}
+
+func container(name string, image string) v.Object {
+	return v.Object{
+		v.String("name"):  v.String(name),
+		v.String("image"): v.String(image),
+	}
+}
+
+var arrayDiffTests = []arrayDiffTest{
+	arrayDiffTest{
+		name: "key insert at front",
+		opts: ArrayOptions{Diff: KeyDiff, Key: "name"},
+		old:  v.Array{container("a", "1"), container("b", "1")},
+		new:  v.Array{container("c", "1"), container("a", "1"), container("b", "1")},
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][name]"), Old: v.String("a"), New: v.String("a")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][name]"), Old: v.String("b"), New: v.String("b")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: CREATE, Path: v.MustParsePath("[containers][0]"), Old: nil, New: container("c", "1")},
+		},
+	},
+	arrayDiffTest{
+		name: "key reorder",
+		opts: ArrayOptions{Diff: KeyDiff, Key: "name"},
+		old:  v.Array{container("a", "1"), container("b", "1"), container("c", "1")},
+		new:  v.Array{container("c", "2"), container("a", "1"), container("b", "1")},
+		operations: []Operation{
+			Operation{Op: MOVE, Path: v.MustParsePath("[containers][0]"), Old: container("c", "1"), New: container("c", "2"), From: v.MustParsePath("[containers][2]")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][0][name]"), Old: v.String("c"), New: v.String("c")},
+			Operation{Op: UPDATE, Path: v.MustParsePath("[containers][0][image]"), Old: v.String("1"), New: v.String("2")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][name]"), Old: v.String("a"), New: v.String("a")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][name]"), Old: v.String("b"), New: v.String("b")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][image]"), Old: v.String("1"), New: v.String("1")},
+		},
+	},
+	arrayDiffTest{
+		name: "key delete",
+		opts: ArrayOptions{Diff: KeyDiff, Key: "name"},
+		old:  v.Array{container("a", "1"), container("b", "1")},
+		new:  v.Array{container("b", "1")},
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[containers][0][name]"), Old: v.String("b"), New: v.String("b")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][0][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: DELETE, Path: v.MustParsePath("[containers]"), Old: container("a", "1"), New: nil, From: v.MustParsePath("[containers][0]")},
+		},
+	},
+	arrayDiffTest{
+		name: "key missing falls back to lcs",
+		opts: ArrayOptions{Diff: KeyDiff, Key: "id"},
+		old:  v.Array{container("a", "1"), container("b", "1")},
+		new:  v.Array{container("c", "1"), container("a", "1"), container("b", "1")},
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][name]"), Old: v.String("a"), New: v.String("a")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][name]"), Old: v.String("b"), New: v.String("b")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2][image]"), Old: v.String("1"), New: v.String("1")},
+			Operation{Op: CREATE, Path: v.MustParsePath("[containers][0]"), Old: nil, New: container("c", "1")},
+		},
+	},
+	arrayDiffTest{
+		name: "lcs insert and delete",
+		opts: ArrayOptions{Diff: LCSDiff},
+		old:  v.Array{v.Integer(1), v.Integer(2), v.Integer(3)},
+		new:  v.Array{v.Integer(0), v.Integer(1), v.Integer(3)},
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1]"), Old: v.Integer(1), New: v.Integer(1)},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2]"), Old: v.Integer(3), New: v.Integer(3)},
+			Operation{Op: DELETE, Path: v.MustParsePath("[containers]"), Old: v.Integer(2), New: nil, From: v.MustParsePath("[containers][1]")},
+			Operation{Op: CREATE, Path: v.MustParsePath("[containers][0]"), Old: nil, New: v.Integer(0)},
+		},
+	},
+	arrayDiffTest{
+		name: "lcs update",
+		opts: ArrayOptions{Diff: LCSDiff},
+		old:  v.Array{v.Integer(1), v.Integer(2), v.Integer(3)},
+		new:  v.Array{v.Integer(1), v.Integer(5), v.Integer(3)},
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[containers][0]"), Old: v.Integer(1), New: v.Integer(1)},
+			Operation{Op: UPDATE, Path: v.MustParsePath("[containers][1]"), Old: v.Integer(2), New: v.Integer(5)},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2]"), Old: v.Integer(3), New: v.Integer(3)},
+		},
+	},
+	arrayDiffTest{
+		name: "lcs move",
+		opts: ArrayOptions{Diff: LCSDiff},
+		old:  v.Array{v.Integer(1), v.Integer(2), v.Integer(3)},
+		new:  v.Array{v.Integer(3), v.Integer(1), v.Integer(2)},
+		operations: []Operation{
+			Operation{Op: MOVE, Path: v.MustParsePath("[containers][0]"), Old: v.Integer(3), New: v.Integer(3), From: v.MustParsePath("[containers][2]")},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][0]"), Old: v.Integer(3), New: v.Integer(3)},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][1]"), Old: v.Integer(1), New: v.Integer(1)},
+			Operation{Op: READ, Path: v.MustParsePath("[containers][2]"), Old: v.Integer(2), New: v.Integer(2)},
+		},
+	},
+	arrayDiffTest{
+		name: "index diff",
+		opts: ArrayOptions{Diff: IndexDiff},
+		old:  v.Array{v.Integer(1), v.Integer(2)},
+		new:  v.Array{v.Integer(0), v.Integer(1), v.Integer(2)},
+		operations: []Operation{
+			Operation{Op: UPDATE, Path: v.MustParsePath("[containers][0]"), Old: v.Integer(1), New: v.Integer(0)},
+			Operation{Op: UPDATE, Path: v.MustParsePath("[containers][1]"), Old: v.Integer(2), New: v.Integer(1)},
+			Operation{Op: CREATE, Path: v.MustParsePath("[containers][2]"), Old: nil, New: v.Integer(2)},
+		},
+	},
+}
+
+func TestArrayDiff(t *testing.T) {
+	for _, c := range arrayDiffTests {
+		old := v.Object{v.String("containers"): c.old}
+		new := v.Object{v.String("containers"): c.new}
+
+		q := CreateOpQueueOptions(old, new, Options{
+			Arrays: map[string]ArrayOptions{"[containers]": c.opts},
+		})
+
+		if q.Len() != len(c.operations) {
+			t.Errorf("Failed on: %s, expected %d operations, got %d", c.name, len(c.operations), q.Len())
+		}
+
+		isOpSequenceEqual(q, c.operations, t)
+	}
+}
+
+type replaceTest struct {
+	name       string
+	opts       Options
+	old        v.Value
+	new        v.Value
+	operations []Operation
+}
+
+func deployment(selector string, replicas int) v.Object {
+	return v.Object{
+		v.String("selector"): v.String(selector),
+		v.String("replicas"): v.Integer(replicas),
+	}
+}
+
+var replaceTests = []replaceTest{
+	replaceTest{
+		name: "force new at root",
+		opts: Options{ForceNew: []string{"[selector]"}},
+		old:  deployment("a", 1),
+		new:  deployment("b", 2),
+		operations: []Operation{
+			Operation{Op: REPLACE, Path: v.MustParsePath(""), Old: deployment("a", 1), New: deployment("b", 2)},
+		},
+	},
+	replaceTest{
+		name: "force new unchanged",
+		opts: Options{ForceNew: []string{"[selector]"}},
+		old:  deployment("a", 1),
+		new:  deployment("a", 2),
+		operations: []Operation{
+			Operation{Op: READ, Path: v.MustParsePath("[selector]"), Old: v.String("a"), New: v.String("a")},
+			Operation{Op: UPDATE, Path: v.MustParsePath("[replicas]"), Old: v.Integer(1), New: v.Integer(2)},
+		},
+	},
+	replaceTest{
+		name: "force new in resource",
+		opts: Options{
+			Resources:          []string{"[web]"},
+			ForceNew:           []string{"[web][selector]"},
+			CreateBeforeDelete: []string{"[web]"},
+		},
+		old: v.Object{v.String("web"): deployment("a", 1), v.String("name"): v.String("web")},
+		new: v.Object{v.String("web"): deployment("b", 1), v.String("name"): v.String("web")},
+		operations: []Operation{
+			Operation{Op: REPLACE, Path: v.MustParsePath("[web]"), Old: deployment("a", 1), New: deployment("b", 1), CreateBeforeDelete: true},
+			Operation{Op: READ, Path: v.MustParsePath("[name]"), Old: v.String("web"), New: v.String("web")},
+		},
+	},
+	replaceTest{
+		name: "force new field removed",
+		opts: Options{ForceNew: []string{"[selector]"}},
+		old:  deployment("a", 1),
+		new:  v.Object{v.String("replicas"): v.Integer(1)},
+		operations: []Operation{
+			Operation{Op: REPLACE, Path: v.MustParsePath(""), Old: deployment("a", 1), New: v.Object{v.String("replicas"): v.Integer(1)}},
+		},
+	},
+	replaceTest{
+		name: "type change",
+		opts: Options{},
+		old:  v.Object{v.String("port"): v.String("80")},
+		new:  v.Object{v.String("port"): v.Integer(80)},
+		operations: []Operation{
+			Operation{Op: REPLACE, Path: v.MustParsePath("[port]"), Old: v.String("80"), New: v.Integer(80)},
+		},
+	},
+}
+
+func TestReplace(t *testing.T) {
+	for _, c := range replaceTests {
+		q := CreateOpQueueOptions(c.old, c.new, c.opts)
+
+		if q.Len() != len(c.operations) {
+			t.Errorf("Failed on: %s, expected %d operations, got %d", c.name, len(c.operations), q.Len())
+		}
+
+		isOpSequenceEqual(q, c.operations, t)
+	}
+}
+
+type orderTest struct {
+	order      Order
+	operations []Operation
+}
+
+var orderOld = v.Object{
+	v.String("b"): v.Integer(1),
+	v.String("a"): v.Integer(1),
+	v.String("d"): v.Integer(1),
+	v.String("c"): v.Integer(1),
+}
+
+var orderNew = v.Object{
+	v.String("a"): v.Integer(2),
+	v.String("f"): v.Integer(1),
+	v.String("b"): v.Integer(1),
+	v.String("e"): v.Integer(1),
+}
+
+var orderTests = []orderTest{
+	orderTest{
+		order: DeletesFirst,
+		operations: []Operation{
+			Operation{Op: UPDATE, Path: v.MustParsePath("[a]"), Old: v.Integer(1), New: v.Integer(2)},
+			Operation{Op: READ, Path: v.MustParsePath("[b]"), Old: v.Integer(1), New: v.Integer(1)},
+			Operation{Op: DELETE, Path: v.MustParsePath("[c]"), Old: v.Integer(1), New: nil},
+			Operation{Op: DELETE, Path: v.MustParsePath("[d]"), Old: v.Integer(1), New: nil},
+			Operation{Op: CREATE, Path: v.MustParsePath("[e]"), Old: nil, New: v.Integer(1)},
+			Operation{Op: CREATE, Path: v.MustParsePath("[f]"), Old: nil, New: v.Integer(1)},
+		},
+	},
+	orderTest{
+		order: CreatesFirst,
+		operations: []Operation{
+			Operation{Op: UPDATE, Path: v.MustParsePath("[a]"), Old: v.Integer(1), New: v.Integer(2)},
+			Operation{Op: READ, Path: v.MustParsePath("[b]"), Old: v.Integer(1), New: v.Integer(1)},
+			Operation{Op: CREATE, Path: v.MustParsePath("[e]"), Old: nil, New: v.Integer(1)},
+			Operation{Op: CREATE, Path: v.MustParsePath("[f]"), Old: nil, New: v.Integer(1)},
+			Operation{Op: DELETE, Path: v.MustParsePath("[c]"), Old: v.Integer(1), New: nil},
+			Operation{Op: DELETE, Path: v.MustParsePath("[d]"), Old: v.Integer(1), New: nil},
+		},
+	},
+}
+
+func TestOperationOrder(t *testing.T) {
+	for _, c := range orderTests {
+		// map iteration order is random, so plan the same configs repeatedly
+		for run := 0; run < 20; run++ {
+			ops := CreateOpQueueOptions(orderOld, orderNew, Options{Order: c.order}).Ops()
+
+			if len(ops) != len(c.operations) {
+				t.Fatalf("Failed on: order %d, expected %d operations, got %d", c.order, len(c.operations), len(ops))
+			}
+
+			for i, op := range ops {
+				if !op.IsEqual(&c.operations[i]) {
+					t.Errorf("Failed on: order %d, operation %d: expected %v, got %v", c.order, i, c.operations[i], *op)
+				}
+			}
+		}
+	}
+}
+
+func TestAmbiguousKeyPaths(t *testing.T) {
+	old := v.Object{
+		v.String("0"):   v.Integer(1),
+		v.String("a]b"): v.Array{v.Integer(1)},
+	}
+
+	new := v.Object{
+		v.String("0"):   v.Integer(2),
+		v.String("a]b"): v.Array{v.Integer(2)},
+	}
+
+	ops := CreateOpQueue(old, new).Ops()
+	want := []string{`["0"]`, `["a]b"][0]`}
+
+	if len(ops) != len(want) {
+		t.Fatalf("Expected %d operations, got %d", len(want), len(ops))
+	}
+
+	for i, op := range ops {
+		if got := op.Path.String(); got != want[i] {
+			t.Errorf("Expected path %s, got %s", want[i], got)
+		}
+
+		if got, _ := v.GetPath(new, op.Path); !v.IsEqual(got, op.New) {
+			t.Errorf("Expected value at %s to be %v, got %v", op.Path, op.New, got)
+		}
+	}
+}
@@ -87,3 +87,15 @@ func (q *OpQueue) Peek() (op *Operation) {
 	res := q.front.value
 	return res
 }
+
+// Ops returns the operations in the queue from front to rear, without removing
+// them from the queue
+func (q *OpQueue) Ops() []*Operation {
+	ops := make([]*Operation, 0, q.length)
+
+	for n := q.front; n != nil; n = n.prev {
+		ops = append(ops, n.value)
+	}
+
+	return ops
+}
@@ -28,7 +28,7 @@ func TestQueueEmpty(t *testing.T) {
 func initQueue(q *OpQueue) (*Operation, *Operation) {
 	op1 := &Operation{
 		Op:   CREATE,
-		Path: "",
+		Path: v.MustParsePath(""),
 		Old:  v.String("hello"),
 		New:  v.Value("there"),
 	}
@@ -37,7 +37,7 @@ func initQueue(q *OpQueue) (*Operation, *Operation) {
 
 	op2 := &Operation{
 		Op:   READ,
-		Path: ".hello",
+		Path: v.MustParsePath(".hello"),
 		Old:  v.String("beep"),
 		New:  v.Value("beep"),
 	}
@@ -60,7 +60,7 @@ func TestQueue(t *testing.T) {
 	}
 
 	// front should be op1
-	if *op1 != *val1 {
+	if op1 != val1 {
 		t.Errorf("Expected queue.Peek to return %v, was %v", *op1, *val1)
 	}
 
@@ -72,7 +72,7 @@ func TestQueue(t *testing.T) {
 	}
 
 	// front should be op1
-	if *op1 != *val2 {
+	if op1 != val2 {
 		t.Errorf("Expected queue.Peek to return %v, was %v", *op1, *val2)
 	}
 
@@ -84,7 +84,28 @@ func TestQueue(t *testing.T) {
 	}
 
 	// front should now be op2
-	if *op2 != *val3 {
+	if op2 != val3 {
 		t.Errorf("Expected queue.Peek to return %v, was %v", *op2, *val3)
 	}
 }
+
+func TestQueueOps(t *testing.T) {
+	q := NewOpQueue()
+
+	if ops := q.Ops(); len(ops) != 0 {
+		t.Errorf("Expected queue.Ops to be empty, was %v", ops)
+	}
+
+	op1, op2 := initQueue(q)
+
+	ops := q.Ops()
+
+	if len(ops) != 2 || ops[0] != op1 || ops[1] != op2 {
+		t.Errorf("Expected queue.Ops to return [%v %v], was %v", op1, op2, ops)
+	}
+
+	// the queue is not modified
+	if len := q.Len(); len != 2 || q.Peek() != op1 {
+		t.Errorf("Expected queue.Len to return 2, was %v", len)
+	}
+}
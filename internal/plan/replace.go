@@ -0,0 +1,70 @@
+package plan
+
+import (
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// sameKind returns true if two values can be updated in place: they have the
+// same type, or are both numbers
+func sameKind(old v.Value, new v.Value) bool {
+	switch old.(type) {
+	case v.Integer, v.Float:
+		switch new.(type) {
+		case v.Integer, v.Float:
+			return true
+		}
+
+		return false
+	case v.Boolean:
+		_, ok := new.(v.Boolean)
+		return ok
+	case v.String:
+		_, ok := new.(v.String)
+		return ok
+	case v.Array:
+		_, ok := new.(v.Array)
+		return ok
+	case v.Object:
+		_, ok := new.(v.Object)
+		return ok
+	}
+
+	return false
+}
+
+// forcesNew returns true if the value at prefix must be replaced, because it is
+// the resource that contains a ForceNew path whose value changed
+func (opts *Options) forcesNew(old v.Value, new v.Value, prefix v.Path) bool {
+	for _, str := range opts.ForceNew {
+		path, err := v.ParsePath(str)
+
+		if err != nil || !opts.resourceOf(path).Equal(prefix) {
+			continue
+		}
+
+		oldVal, oldErr := v.GetPath(old, path[len(prefix):])
+		newVal, newErr := v.GetPath(new, path[len(prefix):])
+
+		if (oldErr == nil) != (newErr == nil) || !v.IsEqual(oldVal, newVal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resourceOf returns the path of the resource that contains path: the longest
+// resource path that prefixes path, or the root path
+func (opts *Options) resourceOf(path v.Path) v.Path {
+	res := v.Path{}
+
+	for _, str := range opts.Resources {
+		resource, err := v.ParsePath(str)
+
+		if err == nil && path.HasPrefix(resource) && len(resource) > len(res) {
+			res = resource
+		}
+	}
+
+	return res
+}
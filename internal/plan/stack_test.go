@@ -28,7 +28,7 @@ func TestStackEmpty(t *testing.T) {
 func initStack(s *OpStack) (*Operation, *Operation) {
 	op1 := &Operation{
 		Op:   CREATE,
-		Path: "",
+		Path: v.MustParsePath(""),
 		Old:  v.String("hello"),
 		New:  v.Value("there"),
 	}
@@ -37,7 +37,7 @@ func initStack(s *OpStack) (*Operation, *Operation) {
 
 	op2 := &Operation{
 		Op:   READ,
-		Path: ".hello",
+		Path: v.MustParsePath(".hello"),
 		Old:  v.String("beep"),
 		New:  v.Value("beep"),
 	}
@@ -55,14 +55,14 @@ func TestStack(t *testing.T) {
 	val1 := s.Peek()
 
 	// should reference the exact same struct
-	if *op2 != *val1 {
+	if op2 != val1 {
 		t.Errorf("Expected stack.Peek to return %v, was %v", *op2, *val1)
 	}
 
 	val2 := s.Pop()
 
 	// should reference the exact same struct
-	if *op2 != *val2 {
+	if op2 != val2 {
 		t.Errorf("Expected stack.Pop to return %v, was %v", *op2, *val2)
 	}
 
@@ -74,14 +74,14 @@ func TestStack(t *testing.T) {
 	val3 := s.Peek()
 
 	// should now be op1
-	if *op1 != *val3 {
+	if op1 != val3 {
 		t.Errorf("Expected stack.Peek to return %v, was %v", *op1, *val3)
 	}
 
 	val4 := s.Pop()
 
 	// should also be op1
-	if *op1 != *val4 {
+	if op1 != val4 {
 		t.Errorf("Expected stack.Peek to return %v, was %v", *op1, *val4)
 	}
 
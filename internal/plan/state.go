@@ -0,0 +1,239 @@
+package plan
+
+import (
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// AppliedState returns the state of a config after some of the operations of
+// the plan from old to new have been applied: every value that only has applied
+// operations is taken from new, and every value that only has operations that
+// were not applied is taken from old. READ operations are ignored.
+//
+// Objects that have both applied and unapplied operations are built key by key.
+// Arrays are patched instead, since the operations on their elements do not all
+// use the same indices: the old elements of applied DELETE and MOVE operations
+// are removed by their old index, and the new elements of applied CREATE and
+// MOVE operations are placed after the element that precedes them in the new
+// array.
+func AppliedState(old v.Value, new v.Value, ops []*Operation, applied func(op *Operation) bool) v.Value {
+	s := appliedState{
+		ops:     make(map[string][]*Operation),
+		applied: applied,
+	}
+
+	for _, op := range ops {
+		if op.Op == READ {
+			continue
+		}
+
+		// index each operation under its path and every ancestor path
+		for i := 0; i <= len(op.Path); i++ {
+			path := op.Path[:i].String()
+			s.ops[path] = append(s.ops[path], op)
+		}
+	}
+
+	return s.state(old, new, v.Path{})
+}
+
+// appliedState indexes the operations of a plan by the paths that they change
+type appliedState struct {
+	ops     map[string][]*Operation
+	applied func(op *Operation) bool
+}
+
+// state returns the state of the value at a path
+func (s *appliedState) state(old v.Value, new v.Value, path v.Path) v.Value {
+	ops := s.ops[path.String()]
+	count := 0
+
+	for _, op := range ops {
+		if s.applied(op) {
+			count++
+		}
+	}
+
+	if count == 0 {
+		return old
+	} else if count == len(ops) {
+		return new
+	}
+
+	// an operation on the whole value was not applied
+	for _, op := range ops {
+		if op.Path.Equal(path) && len(op.From) == 0 && !s.applied(op) {
+			return old
+		}
+	}
+
+	switch oldVal := old.(type) {
+	case v.Object:
+		newVal, ok := new.(v.Object)
+
+		if !ok {
+			return old
+		}
+
+		res := v.Object{}
+
+		for _, k := range sortedKeys(oldVal) {
+			if val := s.state(oldVal[k], newVal[k], path.Key(string(k))); val != nil {
+				res[k] = val
+			}
+		}
+
+		for _, k := range sortedKeys(newVal) {
+			if _, found := oldVal[k]; found {
+				continue
+			}
+
+			if val := s.state(nil, newVal[k], path.Key(string(k))); val != nil {
+				res[k] = val
+			}
+		}
+
+		return res
+	case v.Array:
+		newVal, ok := new.(v.Array)
+
+		if !ok {
+			return old
+		}
+
+		return s.arrayState(oldVal, newVal, path)
+	}
+
+	return old
+}
+
+// arrayState returns the state of an array that has both applied and unapplied
+// operations on its elements. The elements of the old and new arrays are paired
+// as they were by the diff: the elements that are not deleted, created or moved
+// kept their relative order, and are paired in order.
+func (s *appliedState) arrayState(oldArr v.Array, newArr v.Array, path v.Path) v.Value {
+	deleted := make(map[int]*Operation) // by old index
+	created := make(map[int]*Operation) // by new index
+	moved := make(map[int]*Operation)   // by new index
+	movedFrom := make(map[int]int)      // new index by old index
+
+	for _, op := range s.ops[path.String()] {
+		i, isElem := elementIndex(op.Path, path)
+
+		switch {
+		case op.Op == DELETE && len(op.From) > 0:
+			if j, ok := elementIndex(op.From, path); ok {
+				deleted[j] = op
+			}
+		case op.Op == DELETE && isElem:
+			deleted[i] = op
+		case op.Op == CREATE && isElem:
+			created[i] = op
+		case op.Op == MOVE && isElem:
+			if j, ok := elementIndex(op.From, path); ok {
+				moved[i] = op
+				movedFrom[j] = i
+			}
+		}
+	}
+
+	// pair the remaining elements in order
+	pair := make(map[int]int) // new index by old index
+	j := 0
+
+	skipped := func(j int) bool {
+		_, found := movedFrom[j]
+		return found || deleted[j] != nil
+	}
+
+	for i := range newArr {
+		if created[i] != nil || moved[i] != nil {
+			continue
+		}
+
+		for j < len(oldArr) && skipped(j) {
+			j++
+		}
+
+		if j >= len(oldArr) {
+			return oldArr
+		}
+
+		pair[j] = i
+		j++
+	}
+
+	for j, i := range movedFrom {
+		pair[j] = i
+	}
+
+	type elem struct {
+		index int // index in the new array, or -1
+		val   v.Value
+	}
+
+	var res []elem
+
+	// keep the old elements that were not deleted or moved away
+	for j, oldElem := range oldArr {
+		if op := deleted[j]; op != nil {
+			if !s.applied(op) {
+				res = append(res, elem{-1, oldElem})
+			}
+
+			continue
+		}
+
+		i, found := pair[j]
+
+		if !found {
+			res = append(res, elem{-1, oldElem})
+		} else if op := moved[i]; op == nil || !s.applied(op) {
+			res = append(res, elem{i, s.state(oldElem, newArr[i], path.Index(i))})
+		}
+	}
+
+	// place the created and moved elements after their predecessor in the new
+	// array
+	for i := range newArr {
+		var val v.Value
+
+		if op := created[i]; op != nil && s.applied(op) {
+			val = s.state(nil, newArr[i], path.Index(i))
+		} else if op := moved[i]; op != nil && s.applied(op) {
+			j, _ := elementIndex(op.From, path)
+			val = s.state(oldArr[j], newArr[i], path.Index(i))
+		} else {
+			continue
+		}
+
+		pos := 0
+
+		for k, e := range res {
+			if e.index >= 0 && e.index < i {
+				pos = k + 1
+			}
+		}
+
+		res = append(res[:pos], append([]elem{{i, val}}, res[pos:]...)...)
+	}
+
+	arr := v.Array{}
+
+	for _, e := range res {
+		if e.val != nil {
+			arr = append(arr, e.val)
+		}
+	}
+
+	return arr
+}
+
+// elementIndex returns the index of the element of the array at path that p is
+// the path of
+func elementIndex(p v.Path, path v.Path) (int, bool) {
+	if len(p) != len(path)+1 || !p.HasPrefix(path) || !p[len(path)].IsIndex {
+		return 0, false
+	}
+
+	return p[len(path)].Index, true
+}
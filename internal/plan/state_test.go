@@ -0,0 +1,193 @@
+package plan
+
+import (
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type appliedStateTest struct {
+	name    string
+	old     v.Value
+	new     v.Value
+	applied []string // paths of the operations that were applied
+	want    v.Value
+}
+
+var appliedStateOld = v.Object{
+	v.String("keep"):   v.Integer(1),
+	v.String("update"): v.Integer(1),
+	v.String("delete"): v.Integer(1),
+	v.String("list"):   v.Array{v.Integer(1), v.Integer(2)},
+}
+
+var appliedStateNew = v.Object{
+	v.String("keep"):   v.Integer(1),
+	v.String("update"): v.Integer(2),
+	v.String("create"): v.Integer(2),
+	v.String("list"):   v.Array{v.Integer(1), v.Integer(3), v.Integer(4)},
+}
+
+var appliedStateTests = []appliedStateTest{
+	appliedStateTest{
+		name:    "Nothing applied",
+		old:     appliedStateOld,
+		new:     appliedStateNew,
+		applied: []string{},
+		want:    appliedStateOld,
+	},
+	appliedStateTest{
+		name:    "Everything applied",
+		old:     appliedStateOld,
+		new:     appliedStateNew,
+		applied: []string{"[update]", "[delete]", "[create]", "[list][1]", "[list][2]"},
+		want:    appliedStateNew,
+	},
+	appliedStateTest{
+		name:    "Some keys applied",
+		old:     appliedStateOld,
+		new:     appliedStateNew,
+		applied: []string{"[update]", "[delete]"},
+		want: v.Object{
+			v.String("keep"):   v.Integer(1),
+			v.String("update"): v.Integer(2),
+			v.String("list"):   v.Array{v.Integer(1), v.Integer(2)},
+		},
+	},
+	appliedStateTest{
+		name:    "Some indexes applied",
+		old:     appliedStateOld,
+		new:     appliedStateNew,
+		applied: []string{"[create]", "[list][2]"},
+		want: v.Object{
+			v.String("keep"):   v.Integer(1),
+			v.String("update"): v.Integer(1),
+			v.String("delete"): v.Integer(1),
+			v.String("create"): v.Integer(2),
+			v.String("list"):   v.Array{v.Integer(1), v.Integer(2), v.Integer(4)},
+		},
+	},
+	appliedStateTest{
+		name:    "Root created",
+		old:     nil,
+		new:     appliedStateNew,
+		applied: []string{},
+		want:    nil,
+	},
+}
+
+func TestAppliedState(t *testing.T) {
+	for _, test := range appliedStateTests {
+		q := CreateOpQueue(test.old, test.new)
+
+		applied := make(map[string]bool)
+
+		for _, path := range test.applied {
+			applied[path] = true
+		}
+
+		got := AppliedState(test.old, test.new, q.Ops(), func(op *Operation) bool {
+			return applied[op.Path.String()]
+		})
+
+		if !v.IsEqual(got, test.want) {
+			t.Errorf("Failed on: %s, got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+type appliedArrayTest struct {
+	name    string
+	old     v.Array
+	new     v.Array
+	opts    ArrayOptions
+	applied []string // operations that were applied, e.g. "DELETE [0]"
+	want    v.Value
+}
+
+func namedObject(name string, val int) v.Object {
+	return v.Object{
+		v.String("name"):  v.String(name),
+		v.String("value"): v.Integer(val),
+	}
+}
+
+var appliedArrayTests = []appliedArrayTest{
+	appliedArrayTest{
+		name:    "Delete before a kept element applied",
+		old:     v.Array{v.String("a"), v.String("b"), v.String("c")},
+		new:     v.Array{v.String("b"), v.String("c"), v.String("d")},
+		opts:    ArrayOptions{Diff: LCSDiff},
+		applied: []string{"DELETE [0]"},
+		want:    v.Array{v.String("b"), v.String("c")},
+	},
+	appliedArrayTest{
+		name:    "Create after a deleted element applied",
+		old:     v.Array{v.String("a"), v.String("b"), v.String("c")},
+		new:     v.Array{v.String("b"), v.String("c"), v.String("d")},
+		opts:    ArrayOptions{Diff: LCSDiff},
+		applied: []string{"CREATE [2]"},
+		want:    v.Array{v.String("a"), v.String("b"), v.String("c"), v.String("d")},
+	},
+	appliedArrayTest{
+		name:    "Update of a shifted element applied",
+		old:     v.Array{namedObject("a", 1), namedObject("b", 1)},
+		new:     v.Array{namedObject("b", 2), namedObject("c", 1)},
+		opts:    ArrayOptions{Diff: KeyDiff, Key: "name"},
+		applied: []string{"UPDATE [0][value]"},
+		want:    v.Array{namedObject("a", 1), namedObject("b", 2)},
+	},
+	appliedArrayTest{
+		name:    "Delete and update of a shifted element applied",
+		old:     v.Array{namedObject("a", 1), namedObject("b", 1)},
+		new:     v.Array{namedObject("b", 2), namedObject("c", 1)},
+		opts:    ArrayOptions{Diff: KeyDiff, Key: "name"},
+		applied: []string{"DELETE [0]", "UPDATE [0][value]"},
+		want:    v.Array{namedObject("b", 2)},
+	},
+	appliedArrayTest{
+		name:    "Move applied",
+		old:     v.Array{v.String("x"), v.String("y"), v.String("z")},
+		new:     v.Array{v.String("z"), v.String("x"), v.String("y"), v.String("w")},
+		opts:    ArrayOptions{Diff: LCSDiff},
+		applied: []string{"MOVE [0]"},
+		want:    v.Array{v.String("z"), v.String("x"), v.String("y")},
+	},
+	appliedArrayTest{
+		name:    "Create after a moved element applied",
+		old:     v.Array{v.String("x"), v.String("y"), v.String("z")},
+		new:     v.Array{v.String("z"), v.String("x"), v.String("y"), v.String("w")},
+		opts:    ArrayOptions{Diff: LCSDiff},
+		applied: []string{"CREATE [3]"},
+		want:    v.Array{v.String("x"), v.String("y"), v.String("z"), v.String("w")},
+	},
+}
+
+func TestAppliedStateArrays(t *testing.T) {
+	for _, test := range appliedArrayTests {
+		q := CreateOpQueueOptions(test.old, test.new, Options{
+			Arrays: map[string]ArrayOptions{"": test.opts},
+		})
+
+		applied := make(map[string]bool)
+
+		for _, op := range test.applied {
+			applied[op] = true
+		}
+
+		// DELETE operations of elements are identified by their old index
+		got := AppliedState(test.old, test.new, q.Ops(), func(op *Operation) bool {
+			path := op.Path
+
+			if op.Op == DELETE && len(op.From) > 0 {
+				path = op.From
+			}
+
+			return applied[op.Op.String()+" "+path.String()]
+		})
+
+		if !v.IsEqual(got, test.want) {
+			t.Errorf("Failed on: %s, got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
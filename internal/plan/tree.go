@@ -1,5 +1,11 @@
 package plan
 
+import (
+	"sort"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
 type (
 	// OpQueueTree represents a tree of operation queues
 	OpQueueTree struct {
@@ -11,6 +17,7 @@ type (
 		children []*TreeNode
 		parent   *TreeNode
 		val      *OpQueue
+		path     v.Path
 	}
 )
 
@@ -22,13 +29,90 @@ func NewOpQueueTree() *OpQueueTree {
 // AddChild adds a node containing an OpQueue as a child of an existing
 // tree node.
 func (t *OpQueueTree) AddChild(s *OpQueue, parent *TreeNode) (child *TreeNode) {
-	child = &TreeNode{[]*TreeNode{}, parent, s}
+	child = &TreeNode{
+		children: []*TreeNode{},
+		parent:   parent,
+		val:      s,
+	}
 
 	parent.children = append(parent.children, child)
 
 	return child
 }
 
+// NewPathTree organizes the operations of a queue into an OpQueueTree by path.
+// Each node holds the operations at one path, in queue order, and the parent of
+// a node holds the operations at the nearest ancestor path that has operations.
+// The root holds the operations at the root path, and may be empty.
+//
+// DELETE operations of the elements of arrays diffed by key or LCS are held at
+// the old index of the element, From, by nodes of their own, so that they are
+// not mistaken for the operations at the same index of the new array.
+func NewPathTree(q *OpQueue) *OpQueueTree {
+	ops := q.Ops()
+	nodes := make(map[string]*TreeNode)
+
+	t := NewOpQueueTree()
+	t.Root = &TreeNode{
+		children: []*TreeNode{},
+		val:      NewOpQueue(),
+		path:     v.Path{},
+	}
+
+	nodes[""] = t.Root
+
+	// create the nodes of shorter paths first, so that the ancestors of a node
+	// exist when it is created
+	byLength := make([]*Operation, len(ops))
+	copy(byLength, ops)
+
+	sort.SliceStable(byLength, func(i, j int) bool {
+		pi, _ := nodePath(byLength[i])
+		pj, _ := nodePath(byLength[j])
+
+		return len(pi) < len(pj)
+	})
+
+	for _, op := range byLength {
+		path, key := nodePath(op)
+
+		if _, found := nodes[key]; found {
+			continue
+		}
+
+		parent := t.Root
+
+		for i := len(path) - 1; i > 0; i-- {
+			if node, found := nodes[path[:i].String()]; found {
+				parent = node
+				break
+			}
+		}
+
+		child := t.AddChild(NewOpQueue(), parent)
+		child.path = path
+
+		nodes[key] = child
+	}
+
+	for _, op := range ops {
+		_, key := nodePath(op)
+		nodes[key].val.Enqueue(op)
+	}
+
+	return t
+}
+
+// nodePath returns the path of the node that holds an operation in a path tree,
+// and the key of the node
+func nodePath(op *Operation) (v.Path, string) {
+	if op.Op == DELETE && len(op.From) > 0 {
+		return op.From, "<" + op.From.String()
+	}
+
+	return op.Path, op.Path.String()
+}
+
 // Prune removes all empty queues that don't have children from the OpQueueTree.
 func (t *OpQueueTree) Prune() {
 	if t.Root == nil {
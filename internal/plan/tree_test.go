@@ -133,7 +133,7 @@ func TestPruneDeepNotAllEmpty(t *testing.T) {
 
 	s5.Enqueue(&Operation{
 		Op:   READ,
-		Path: ".hello",
+		Path: v.MustParsePath(".hello"),
 		Old:  v.String("hello"),
 		New:  v.Value("there"),
 	})
@@ -190,3 +190,29 @@ func TestGetNLeaves(t *testing.T) {
 		t.Errorf("Expected GetNLeaves(5) to return 4 leaves, got %v", len)
 	}
 }
+
+func TestNewPathTree(t *testing.T) {
+	q := initExecQueue("[a][x]", "[b]", "[a]", "[a][x][y]", "[c][z]")
+
+	tree := NewPathTree(q)
+
+	if tree.Root.val.Len() != 0 || len(tree.Root.children) != 3 {
+		t.Fatalf("Expected an empty root with 3 children, got %d operations and %d children", tree.Root.val.Len(), len(tree.Root.children))
+	}
+
+	var a *TreeNode
+
+	for _, child := range tree.Root.children {
+		if child.path.String() == "[a]" {
+			a = child
+		}
+	}
+
+	if a == nil || len(a.children) != 1 || a.children[0].path.String() != "[a][x]" {
+		t.Fatalf("Expected [a] to have the single child [a][x]")
+	}
+
+	if x := a.children[0]; len(x.children) != 1 || x.children[0].path.String() != "[a][x][y]" {
+		t.Errorf("Expected [a][x] to have the single child [a][x][y]")
+	}
+}
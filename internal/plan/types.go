@@ -1,25 +1,115 @@
 package plan
 
-import v "github.com/porterdev/ego/internal/value"
+import (
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+)
 
 type (
 	// OpType represents an enumerated operation type
 	OpType int
 
 	// Operation represents an executable operation that takes a Value from an
-	// old state to a new state
+	// old state to a new state. Path is the path of the Value in the new state,
+	// and From is a path in the old state: the path that the Value is moved from
+	// for MOVE operations, and the path of the deleted element for DELETE
+	// operations of arrays diffed by key or LCS, whose Path is the array. Array
+	// indexes in Path are therefore always indexes of the new array, and indexes
+	// in From of the old array. CreateBeforeDelete is only set for REPLACE
+	// operations whose new Value must be created before the old Value is
+	// deleted.
 	Operation struct {
 		Op   OpType
-		Path string
+		Path v.Path
 		Old  v.Value
 		New  v.Value
+		From v.Path
+
+		CreateBeforeDelete bool
 	}
 )
 
-// The operation enumeration types: CREATE, READ, UPDATE, DELETE
+// The operation enumeration types: CREATE, READ, UPDATE, DELETE, MOVE, REPLACE.
+// A REPLACE operation deletes the old Value and creates the new Value, since the
+// Value cannot be updated in place.
 const (
 	CREATE OpType = iota
 	READ
 	UPDATE
 	DELETE
+	MOVE
+	REPLACE
+)
+
+type (
+	// ArrayDiff is an algorithm that matches the elements of an old and a new
+	// array, so that only the elements that differ generate operations
+	ArrayDiff int
+
+	// ArrayOptions configures how the arrays at a path are diffed. Key is the
+	// field that identifies the objects in the arrays, and is only used by
+	// KeyDiff.
+	ArrayOptions struct {
+		Diff ArrayDiff
+		Key  string
+	}
+
+	// Order is the order of the DELETE and CREATE operations of an object or
+	// array, relative to each other. Execute keeps the order at any Parallelism.
+	Order int
+
+	// Options configures how CreateOpQueueOptions diffs an old and a new
+	// config. Resources are the paths that are diffed as a whole, see
+	// CreateOpQueue, and Arrays configures the arrays at a path. Paths are
+	// parsed by value.ParsePath, e.g. "[spec][containers]" or "spec.containers".
+	//
+	// The operations of an object are enqueued in a stable order: shared keys
+	// first, then deleted and created keys in the order set by Order, each in
+	// sorted key order.
+	//
+	// ForceNew are the paths of immutable fields: if the value at one of these
+	// paths changes, the resource that contains it, i.e. the longest Resources
+	// path that prefixes it, or the whole config, is replaced. REPLACE operations
+	// at the CreateBeforeDelete paths create the new value before deleting the
+	// old one.
+	Options struct {
+		Resources []string
+		Arrays    map[string]ArrayOptions
+
+		ForceNew           []string
+		CreateBeforeDelete []string
+
+		Order Order
+	}
+)
+
+// The array diff algorithms: IndexDiff matches elements by index, and is the
+// default. LCSDiff matches equal elements by their longest common subsequence,
+// and pairs the remaining elements in between as updates. KeyDiff matches
+// objects that have the same value for a key field, e.g. "name", and falls back
+// to LCSDiff if an element has no value or a duplicate value for the key.
+const (
+	IndexDiff ArrayDiff = iota
+	LCSDiff
+	KeyDiff
 )
+
+// The orders of DELETE and CREATE operations: DeletesFirst is the default, so
+// that a created value never conflicts with a value that is being deleted.
+const (
+	DeletesFirst Order = iota
+	CreatesFirst
+)
+
+// ExecOptions configures Execute. Parallelism is the maximum number of
+// operations that run at the same time, and is 1 if it is not set. DependsOn
+// maps a path to the paths that it depends on: the operations at or under the
+// path run after every operation at or under the paths it depends on. Timeout
+// is the maximum duration of each operation if it is set: the context of an
+// operation is cancelled once it expires.
+type ExecOptions struct {
+	Parallelism int
+	DependsOn   map[string][]string
+	Timeout     time.Duration
+}
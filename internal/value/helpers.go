@@ -82,89 +82,39 @@ func IsEqual(v1, v2 Value) bool {
 	}
 }
 
-// Get retrieves a Value at a certain path within a configuration object
-func Get(v Value, path string) (Value, error) {
-	if path == "" {
-		return v, nil
-	}
-
-	offs := 0
-	curr := rune(path[0])
-
-	// if here, indexing array or object
-	if curr == '[' {
-		for curr != ']' {
-			offs++
-			curr = rune(path[offs])
-		}
-
-		// make sure we're at an object or an array
-		switch v.(type) {
-		case Array:
-			vArr := v.(Array)
-
-			// parse path to integer
-			i, err := strconv.ParseInt(path[1:offs], 10, 64)
-
-			if err != nil {
-				return nil, err
-			}
-
-			if len(path) == offs+1 {
-				return vArr[i], nil
-			}
-
-			return Get(vArr[i], path[offs+1:])
-		case Object:
-			vObj := v.(Object)
-
-			if len(path) == offs+1 {
-				return vObj[String(path[1:offs])], nil
-			}
-
-			return Get(vObj[String(path[1:offs])], path[offs+1:])
-		default:
-			return nil, fmt.Errorf("Not an object, cannot index using brackets []")
-		}
-	}
-
-	// if leading ., indexing on object -- can just remove period
-	if curr == '.' {
-		if len(path) > 1 {
-			return Get(v, path[1:])
-		}
-
-		return nil, fmt.Errorf("Path cannot end in period (.)")
+// Stringify converts a scalar value to the string used when the value is
+// interpolated into a string. Arrays and objects cannot be stringified.
+func Stringify(v Value) (string, error) {
+	if v == nil {
+		return "null", nil
 	}
 
-	// iterate until period
-	for i := 0; i < len(path); i++ {
-		curr = rune(path[i])
-
-		if curr == '.' || curr == '[' {
-			// make sure we're at an object
-			vObj, ok := v.(Object)
-
-			if !ok {
-				return nil, fmt.Errorf("Not an object: cannot index (cannot use [] or .)")
-			}
-
-			if len(path) > i && curr == '.' {
-				return Get(vObj[String(path[offs:i])], path[i+1:])
-			} else if len(path) > i && curr == '[' {
-				return Get(vObj[String(path[offs:i])], path[i:])
-			}
-
-			return nil, fmt.Errorf("Cannot end in period (.)")
-		}
+	switch v.(type) {
+	case Boolean:
+		return strconv.FormatBool(bool(v.(Boolean))), nil
+	case Float:
+		return strconv.FormatFloat(float64(v.(Float)), 'f', -1, 64), nil
+	case Integer:
+		return strconv.Itoa(int(v.(Integer))), nil
+	case String:
+		return string(v.(String)), nil
+	case Array:
+		return "", fmt.Errorf("Cannot convert an array to a string")
+	case Object:
+		return "", fmt.Errorf("Cannot convert an object to a string")
+	default:
+		return "", fmt.Errorf("Value does not contain a supported Porter type")
 	}
+}
 
-	// if here, must be indexing an object
-	vObj, ok := v.(Object)
+// Get retrieves a Value at a certain path within a configuration object. The
+// path is parsed by ParsePath, see GetPath.
+func Get(v Value, path string) (Value, error) {
+	p, err := ParsePath(path)
 
-	if !ok {
-		return nil, fmt.Errorf("Not an object: cannot index on a field")
+	if err != nil {
+		return nil, err
 	}
 
-	return vObj[String(path[0:len(path)])], nil
+	return GetPath(v, p)
 }
@@ -298,3 +298,30 @@ func TestGet(t *testing.T) {
 		}
 	}
 }
+
+type stringifyTest struct {
+	name string
+	v    Value
+	want string
+	err  bool
+}
+
+var stringifyTests = []stringifyTest{
+	stringifyTest{name: "Stringify: string", v: String("foo"), want: "foo"},
+	stringifyTest{name: "Stringify: integer", v: Integer(-12), want: "-12"},
+	stringifyTest{name: "Stringify: float", v: Float(1.25), want: "1.25"},
+	stringifyTest{name: "Stringify: boolean", v: Boolean(false), want: "false"},
+	stringifyTest{name: "Stringify: null", v: nil, want: "null"},
+	stringifyTest{name: "Stringify: array", v: Array{}, err: true},
+	stringifyTest{name: "Stringify: object", v: Object{}, err: true},
+}
+
+func TestStringify(t *testing.T) {
+	for _, c := range stringifyTests {
+		got, err := Stringify(c.v)
+
+		if got != c.want || (err != nil) != c.err {
+			t.Errorf("Failed on: %s, %s, %v", c.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// PathSegment is a segment of a Path: either the key of an object, or the
+	// index of an array if IsIndex is true
+	PathSegment struct {
+		Key     string
+		Index   int
+		IsIndex bool
+	}
+
+	// Path is the location of a Value within a configuration, as a sequence of
+	// object keys and array indexes. The empty Path is the configuration itself.
+	Path []PathSegment
+)
+
+// ParsePath parses a path of the form [key][0], where each segment is either
+// an array index or an object key. Keys that would be ambiguous, i.e. keys that
+// are empty, only contain digits, or contain [, ], " or \, are quoted, e.g.
+// ["0"] or ["a]b"]. Object keys can also be written with periods, e.g.
+// spec.containers[0].name, in which case they are never array indexes.
+func ParsePath(path string) (Path, error) {
+	res := Path{}
+
+	for offs := 0; offs < len(path); {
+		if path[offs] == '[' {
+			seg, n, err := parseBracket(path[offs:])
+
+			if err != nil {
+				return nil, fmt.Errorf("Path %s, offset %d: %v", path, offs, err)
+			}
+
+			res = append(res, seg)
+			offs += n
+
+			continue
+		}
+
+		// a key after a period, or at the start of the path
+		start := offs
+
+		if path[offs] == '.' {
+			start++
+		}
+
+		end := start + strings.IndexAny(path[start:]+".", ".[")
+
+		if end == start {
+			return nil, fmt.Errorf("Path %s, offset %d: empty key after period", path, offs)
+		}
+
+		res = append(res, PathSegment{Key: path[start:end]})
+		offs = end
+	}
+
+	return res, nil
+}
+
+// parseBracket parses a segment of a path in brackets, and returns the segment
+// and its length
+func parseBracket(path string) (PathSegment, int, error) {
+	if len(path) > 1 && path[1] == '"' {
+		// find the closing quote, skipping escaped characters
+		end := 2
+
+		for end < len(path) && path[end] != '"' {
+			if path[end] == '\\' {
+				end++
+			}
+
+			end++
+		}
+
+		if end+1 >= len(path) || path[end+1] != ']' {
+			return PathSegment{}, 0, fmt.Errorf("unterminated quoted key")
+		}
+
+		key, err := strconv.Unquote(path[1 : end+1])
+
+		if err != nil {
+			return PathSegment{}, 0, fmt.Errorf("invalid quoted key: %v", err)
+		}
+
+		return PathSegment{Key: key}, end + 2, nil
+	}
+
+	end := strings.IndexByte(path, ']')
+
+	if end < 0 {
+		return PathSegment{}, 0, fmt.Errorf("missing ]")
+	} else if end == 1 {
+		return PathSegment{}, 0, fmt.Errorf("empty brackets []")
+	}
+
+	body := path[1:end]
+
+	if isDigits(body) {
+		i, err := strconv.Atoi(body)
+
+		if err != nil {
+			return PathSegment{}, 0, err
+		}
+
+		return PathSegment{Index: i, IsIndex: true}, end + 1, nil
+	}
+
+	return PathSegment{Key: body}, end + 1, nil
+}
+
+// MustParsePath is like ParsePath, but panics if the path cannot be parsed.
+func MustParsePath(path string) Path {
+	res, err := ParsePath(path)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}
+
+// Key returns a new Path that indexes the object at p with key.
+func (p Path) Key(key string) Path {
+	return p.append(PathSegment{Key: key})
+}
+
+// Index returns a new Path that indexes the array at p with i.
+func (p Path) Index(i int) Path {
+	return p.append(PathSegment{Index: i, IsIndex: true})
+}
+
+// append returns a copy of p with seg appended, so that paths that share a
+// prefix never share their segments
+func (p Path) append(seg PathSegment) Path {
+	res := make(Path, len(p), len(p)+1)
+	copy(res, p)
+
+	return append(res, seg)
+}
+
+// String formats the path as parsed by ParsePath, e.g. [spec][containers][0].
+func (p Path) String() string {
+	var b strings.Builder
+
+	for _, seg := range p {
+		b.WriteString(seg.String())
+	}
+
+	return b.String()
+}
+
+// String formats the segment in brackets, quoting keys that would be ambiguous.
+func (seg PathSegment) String() string {
+	if seg.IsIndex {
+		return "[" + strconv.Itoa(seg.Index) + "]"
+	}
+
+	return "[" + EscapeKey(seg.Key) + "]"
+}
+
+// EscapeKey returns a key as it is written between the brackets of a path: as
+// is, or quoted if the key is empty, only contains digits, or contains [, ], "
+// or \.
+func EscapeKey(key string) string {
+	if key == "" || isDigits(key) || strings.ContainsAny(key, `[]"\`) {
+		return strconv.Quote(key)
+	}
+
+	return key
+}
+
+// Equal returns true if two paths have the same segments.
+func (p Path) Equal(q Path) bool {
+	if len(p) != len(q) {
+		return false
+	}
+
+	for i := range p {
+		if p[i] != q[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasPrefix returns true if the first segments of p are the segments of prefix.
+func (p Path) HasPrefix(prefix Path) bool {
+	return len(p) >= len(prefix) && p[:len(prefix)].Equal(prefix)
+}
+
+// Pointer formats the path as a JSON Pointer (RFC 6901), e.g.
+// /spec/containers/0.
+func (p Path) Pointer() string {
+	var b strings.Builder
+
+	for _, seg := range p {
+		b.WriteByte('/')
+
+		if seg.IsIndex {
+			b.WriteString(strconv.Itoa(seg.Index))
+		} else {
+			b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(seg.Key))
+		}
+	}
+
+	return b.String()
+}
+
+// ParsePointer parses a JSON Pointer (RFC 6901). Since a JSON Pointer does not
+// distinguish array indexes from object keys, every segment of the path is a
+// key, which GetPath resolves as an index on arrays.
+func ParsePointer(pointer string) (Path, error) {
+	res := Path{}
+
+	if pointer == "" {
+		return res, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON Pointer %s must start with /", pointer)
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		// ~ can only be followed by 0 or 1
+		for i := 0; i < len(token); i++ {
+			if token[i] == '~' && (i+1 == len(token) || (token[i+1] != '0' && token[i+1] != '1')) {
+				return nil, fmt.Errorf("JSON Pointer %s contains an invalid escape sequence", pointer)
+			}
+		}
+
+		key := strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		res = append(res, PathSegment{Key: key})
+	}
+
+	return res, nil
+}
+
+// GetPath retrieves the Value at a path within a configuration. A key is
+// resolved as an index on arrays if it is a decimal number, and an index is
+// resolved as a key on objects. The Value at a missing object key is nil.
+func GetPath(v Value, p Path) (Value, error) {
+	for _, seg := range p {
+		switch curr := v.(type) {
+		case Object:
+			key := seg.Key
+
+			if seg.IsIndex {
+				key = strconv.Itoa(seg.Index)
+			}
+
+			v = curr[String(key)]
+		case Array:
+			index := seg.Index
+
+			if !seg.IsIndex {
+				if !isDigits(seg.Key) || (len(seg.Key) > 1 && seg.Key[0] == '0') {
+					return nil, fmt.Errorf("Path %s: %s is not an array index", p, seg.Key)
+				}
+
+				index, _ = strconv.Atoi(seg.Key)
+			}
+
+			if index < 0 || index >= len(curr) {
+				return nil, fmt.Errorf("Path %s: index %d out of range", p, index)
+			}
+
+			v = curr[index]
+		default:
+			return nil, fmt.Errorf("Path %s: cannot index a value that is not an object or array with %s", p, seg)
+		}
+	}
+
+	return v, nil
+}
+
+// isDigits returns true if str is a non-empty sequence of decimal digits
+func isDigits(str string) bool {
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return str != ""
+}
@@ -0,0 +1,149 @@
+package value
+
+import (
+	"testing"
+)
+
+type pathTest struct {
+	name    string
+	path    string
+	want    Path
+	str     string
+	pointer string
+	err     bool
+}
+
+var pathTests = []pathTest{
+	pathTest{
+		name:    "Root path",
+		path:    "",
+		want:    Path{},
+		str:     "",
+		pointer: "",
+	},
+	pathTest{
+		name: "Keys and indexes",
+		path: "[spec][containers][0][name]",
+		want: Path{
+			PathSegment{Key: "spec"},
+			PathSegment{Key: "containers"},
+			PathSegment{Index: 0, IsIndex: true},
+			PathSegment{Key: "name"},
+		},
+		str:     "[spec][containers][0][name]",
+		pointer: "/spec/containers/0/name",
+	},
+	pathTest{
+		name: "Period syntax",
+		path: "spec.containers[1].name",
+		want: Path{
+			PathSegment{Key: "spec"},
+			PathSegment{Key: "containers"},
+			PathSegment{Index: 1, IsIndex: true},
+			PathSegment{Key: "name"},
+		},
+		str:     "[spec][containers][1][name]",
+		pointer: "/spec/containers/1/name",
+	},
+	pathTest{
+		name: "Quoted keys",
+		path: `["0"]["a]b"]["~/\""][a.b]`,
+		want: Path{
+			PathSegment{Key: "0"},
+			PathSegment{Key: "a]b"},
+			PathSegment{Key: `~/"`},
+			PathSegment{Key: "a.b"},
+		},
+		str:     `["0"]["a]b"]["~/\""][a.b]`,
+		pointer: "/0/a]b/~0~1\"/a.b",
+	},
+	pathTest{name: "Missing bracket", path: "[spec", err: true},
+	pathTest{name: "Empty brackets", path: "[]", err: true},
+	pathTest{name: "Unterminated quote", path: `["spec]`, err: true},
+	pathTest{name: "Trailing period", path: "spec.", err: true},
+}
+
+func TestParsePath(t *testing.T) {
+	for _, c := range pathTests {
+		got, err := ParsePath(c.path)
+
+		if c.err {
+			if err == nil {
+				t.Errorf("Failed on: %s, expected an error", c.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Failed on: %s, %v", c.name, err)
+			continue
+		}
+
+		if !got.Equal(c.want) {
+			t.Errorf("Failed on: %s, parsed %#v", c.name, got)
+		}
+
+		if str := got.String(); str != c.str {
+			t.Errorf("Failed on: %s, String() == %s, want %s", c.name, str, c.str)
+		}
+
+		if pointer := got.Pointer(); pointer != c.pointer {
+			t.Errorf("Failed on: %s, Pointer() == %s, want %s", c.name, pointer, c.pointer)
+		}
+
+		// the formatted path parses back to the same path
+		if res, err := ParsePath(got.String()); err != nil || !res.Equal(got) {
+			t.Errorf("Failed on: %s, %s does not round trip", c.name, got)
+		}
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	got, err := ParsePointer("/spec/containers/0/a~1b~0c~01")
+
+	want := Path{
+		PathSegment{Key: "spec"},
+		PathSegment{Key: "containers"},
+		PathSegment{Key: "0"},
+		PathSegment{Key: "a/b~c~1"},
+	}
+
+	if err != nil || !got.Equal(want) {
+		t.Errorf("ParsePointer() == %#v, %v", got, err)
+	}
+
+	for _, pointer := range []string{"spec", "/a~2", "/a~"} {
+		if _, err := ParsePointer(pointer); err == nil {
+			t.Errorf("ParsePointer(%q) did not return an error", pointer)
+		}
+	}
+}
+
+func TestGetPath(t *testing.T) {
+	val := Object{
+		"spec": Object{
+			"containers": Array{
+				Object{"name": String("web")},
+			},
+			"0": String("zero"),
+		},
+	}
+
+	pointer, _ := ParsePointer("/spec/containers/0/name")
+
+	if got, err := GetPath(val, pointer); err != nil || !IsEqual(got, String("web")) {
+		t.Errorf("GetPath() with a JSON Pointer == %v, %v", got, err)
+	}
+
+	if got, err := GetPath(val, MustParsePath(`[spec]["0"]`)); err != nil || !IsEqual(got, String("zero")) {
+		t.Errorf("GetPath() with a quoted key == %v, %v", got, err)
+	}
+
+	// malformed paths and out of range indexes are errors, not panics
+	for _, path := range []string{"spec.containers[1]", "spec.containers[0].name.first", "spec[containers", "spec.containers.01"} {
+		if _, err := Get(val, path); err == nil {
+			t.Errorf("Get(%q) did not return an error", path)
+		}
+	}
+}
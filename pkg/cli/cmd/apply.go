@@ -4,108 +4,246 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/porterdev/ego/pkg/json"
 	"github.com/porterdev/ego/pkg/porter"
 	t "github.com/porterdev/ego/pkg/translator"
 )
 
-// applyCmd represents the apply command
-var applyCmd = &cobra.Command{
-	Args: func(cmd *cobra.Command, args []string) error {
-		if len(args) != 1 {
-			return errors.New("requires a path to a file\n ")
-		}
+// programFlags are the flags of the commands that build and run a
+// configuration program
+type programFlags struct {
+	input        string
+	inputFile    string
+	frameworkDir string
+}
 
-		err := porter.FileExists(args[0])
+var applyFlags programFlags
 
-		if err != nil {
-			return err
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Args:  programArgs,
+	Use:   "apply [filename|directory|planfile]",
+	Short: "Applies a .ego program -- generates configuration and runs it.",
+	Long: `Translates a .ego program to Go, builds it in a temporary Go module and runs
+it in the current directory, streaming its logs. The input of the configuration
+is passed as JSON with --input or --input-file.
+
+The program is the main package in the directory of the given file, or the
+given directory, together with the packages in its subdirectories. Every .ego,
+.gop and .go file in these directories is part of the program. If the
+directory contains a go.mod file, its module path and requirements are used.
+
+The program is built against a local copy of the Porter framework, given by
+--framework-dir or $PORTER_FRAMEWORK_DIR, or else found in the parent
+directories of the program, of the porter executable, or of the source porter
+was built from. Without a go.mod file, the program is built as the module
+github.com/porterdev/ego/build, nested under the framework, so that it can
+import the framework's internal packages such as internal/value. A program with
+its own go.mod file must use a module path under github.com/porterdev/ego to
+import them.
+
+If the given file is a plan saved by plan --out, the program that the plan was
+made from is built, and the saved plan is applied instead of a new plan. The
+saved plan is refused if the state has changed since the plan was made.
+
+The exit status is the exit status of the configuration program.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// the arguments are valid, so errors are not usage errors
+		cmd.SilenceUsage = true
+
+		if isPlanFile(args[0]) {
+			return programError(applyPlanFile(args[0], applyFlags))
 		}
 
-		return nil
-	},
-	Use:   "apply [filename]",
-	Short: "Applies a .gop file -- generates configuration and runs it.",
-	Long:  `TBD -- not sure what this will accept yet.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		filename := args[0]
-		apply(filename)
+		return programError(runProgram(args[0], applyFlags, porter.ModeEnv+"="+porter.ModeApply))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(applyCmd)
 
-	// Here you will define your flags and configuration settings.
+	addProgramFlags(applyCmd, &applyFlags)
+}
+
+// programArgs validates that the only argument of a command is the path of a
+// configuration program
+func programArgs(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("requires a path to a file\n ")
+	}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// applyCmd.PersistentFlags().String("foo", "", "A help for foo")
+	if !porter.FileExists(args[0]) && !porter.IsDirectory(args[0]) {
+		return fmt.Errorf("file or directory %s does not exist", args[0])
+	}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// applyCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	return nil
 }
 
-func apply(filename string) {
-	// TODO -- from the entry point, find all .gop files in the same directory or below
+// addProgramFlags adds the flags of the commands that build and run a
+// configuration program to cmd
+func addProgramFlags(cmd *cobra.Command, f *programFlags) {
+	cmd.Flags().StringVar(&f.input, "input", "", "input of the configuration, as JSON")
+	cmd.Flags().StringVar(&f.inputFile, "input-file", "", "path to a JSON file containing the input of the configuration")
+	cmd.Flags().StringVar(&f.frameworkDir, "framework-dir", os.Getenv(frameworkDirEnv), "path to a local copy of the Porter framework to build with (default $"+frameworkDirEnv+", or found by looking in parent directories)")
+}
 
-	// read the entry point
-	dat, err := ioutil.ReadFile(filename)
+// runProgram builds and runs the configuration program at path, with env added
+// to its environment, and returns its exit status
+func runProgram(path string, f programFlags, env ...string) (int, error) {
+	input, err := readInput(f.input, f.inputFile)
 
 	if err != nil {
-		fmt.Println("Error while reading file:", err)
+		return 1, err
 	}
 
-	// translate the file to Golang
-	trans := t.NewTranslator(dat)
+	p, err := buildProgram(path, f.frameworkDir)
 
-	res := trans.TranslateToJSON()
+	if err != nil {
+		return 1, fmt.Errorf("error while building %s: %w", path, err)
+	}
 
-	fmt.Println(string(res))
+	defer p.cleanup()
 
-	dir, err := ioutil.TempDir("./", "build")
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	env = append(env, porter.InputEnv+"="+input, porter.ProgramEnv+"="+path)
+
+	return p.run(env, os.Stdout, os.Stderr)
+}
+
+// isPlanFile returns true if path is a file that is not part of a program, and
+// is therefore a saved plan
+func isPlanFile(path string) bool {
+	return porter.FileExists(path) && !porter.IsDirectory(path) && !isSourceFile(path) && filepath.Ext(path) != ".go"
+}
+
+// applyPlanFile builds the program that a saved plan was made from, and runs
+// it to apply the saved plan
+func applyPlanFile(filename string, f programFlags) (int, error) {
+	if f.input != "" || f.inputFile != "" {
+		return 1, errors.New("--input and --input-file cannot be used with a saved plan")
+	}
+
+	p, err := porter.ReadPlanFile(filename)
 
 	if err != nil {
-		fmt.Println(err)
-		panic("ouch")
+		return 1, err
 	}
 
-	defer os.RemoveAll(dir)
+	if p.Program == "" {
+		return 1, fmt.Errorf("plan file %s does not record the program it was made from", filename)
+	}
 
-	// TODO -- SUPPORT OTHER FILES THAN MAIN
-	file, err := ioutil.TempFile("./"+dir, "build_*.go")
+	abs, err := filepath.Abs(filename)
 
 	if err != nil {
-		fmt.Println(err)
-		panic("ouch")
+		return 1, err
 	}
 
-	file.Write(res)
+	return runProgram(p.Program, f, porter.ModeEnv+"="+porter.ModeApply, porter.PlanFileEnv+"="+abs)
+}
 
-	fmt.Println(file.Name())
+// readInput returns the JSON input of a configuration from either a string or
+// a file, and verifies that it is valid JSON
+func readInput(input string, inputFile string) (string, error) {
+	if input != "" && inputFile != "" {
+		return "", errors.New("only one of --input and --input-file can be set")
+	}
 
-	// compile the file
-	cmd := exec.Command("go", "build", "./"+file.Name())
+	if inputFile != "" {
+		dat, err := ioutil.ReadFile(inputFile)
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.Fatal(err)
+		if err != nil {
+			return "", fmt.Errorf("error while reading input file: %v", err)
+		}
+
+		input = string(dat)
+	}
+
+	if input == "" {
+		return "", nil
+	}
+
+	if _, err := json.Parse([]byte(input)); err != nil {
+		return "", fmt.Errorf("invalid input: %v", err)
+	}
+
+	return input, nil
+}
+
+// checkHeredocs checks the syntax of every JSON heredoc in a source file, and
+// prints each error with its position in the source file and a caret underline.
+func checkHeredocs(filename string, src []byte) error {
+	trans := t.NewTranslator(src)
+	count := 0
+
+	for _, h := range trans.Heredocs() {
+		if t.Resolve(h.Name) != "json" {
+			continue
+		}
+
+		for _, synErr := range json.Check(h.Body) {
+			// rewrite positions relative to the heredoc as positions in the file
+			fileErr := *synErr
+			fileErr.Pos = heredocPosition(h, synErr.Pos)
+			fileErr.TokenPos = heredocPosition(h, synErr.TokenPos)
+
+			msg := fileErr.Msg
+
+			if len(fileErr.Expected) > 0 {
+				expected := make([]string, len(fileErr.Expected))
+
+				for i, tok := range fileErr.Expected {
+					expected[i] = tok.String()
+				}
+
+				msg += " (expected " + strings.Join(expected, ", ") + ")"
+			}
+
+			pos := fileErr.TokenPos
+
+			if pos.Line == 0 {
+				pos = fileErr.Pos
+			}
+
+			fmt.Printf("%s:%d:%d: %s\n", filename, pos.Line, pos.Column, msg)
+			fmt.Println(fileErr.Underline(src))
+
+			count++
+		}
 	}
 
-	if err := cmd.Start(); err != nil {
-		log.Fatal(err)
+	if count > 0 {
+		return fmt.Errorf("found %d syntax errors in %s", count, filename)
 	}
 
-	slurp, _ := ioutil.ReadAll(stderr)
-	fmt.Printf("%s\n", slurp)
+	return nil
+}
+
+// heredocPosition converts a position within the body of a heredoc to a position
+// within the source file
+func heredocPosition(h t.Heredoc, pos json.Position) json.Position {
+	if pos.Line == 0 {
+		return pos
+	}
 
-	if err := cmd.Wait(); err != nil {
-		log.Fatal(err)
+	res := json.Position{
+		Offset: h.Pos.Offset + pos.Offset,
+		Line:   h.Pos.Line + pos.Line - 1,
+		Column: pos.Column,
 	}
+
+	if pos.Line == 1 {
+		res.Column = h.Pos.Column + pos.Column - 1
+	}
+
+	return res
 }
@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/porterdev/ego/pkg/porter"
+	t "github.com/porterdev/ego/pkg/translator"
+)
+
+// frameworkModule is the module path of the Porter framework
+const frameworkModule = "github.com/porterdev/ego"
+
+// buildModule is the module path of the temporary module that a configuration
+// program is built in. It is nested under the framework module path, so that
+// configuration programs can import the framework's internal packages.
+const buildModule = frameworkModule + "/build"
+
+// frameworkDirEnv is the environment variable that holds the default path of a
+// local copy of the framework
+const frameworkDirEnv = "PORTER_FRAMEWORK_DIR"
+
+// sourceExts are the extensions of the files that are translated to Go
+var sourceExts = []string{".ego", ".gop"}
+
+// program is a configuration program that has been translated and built in a
+// temporary Go module
+type program struct {
+	dir    string // directory of the temporary module
+	binary string // path of the built binary
+
+	// source maps of the translated files, by path relative to dir
+	sourceMaps map[string]*t.SourceMap
+}
+
+// buildProgram translates and builds a configuration program as a temporary
+// Go module. path is either the entry file of the program, or the directory of
+// its main package. Every .ego, .gop and .go file in the directory of the main
+// package and its subdirectories is part of the build tree, so that a program
+// can be split into several files and packages.
+//
+// The module uses the framework found in frameworkDir, or found by
+// findFrameworkDir if frameworkDir is empty, unless the go.mod file of the
+// program already replaces the framework.
+func buildProgram(path string, frameworkDir string) (*program, error) {
+	root := path
+
+	if !porter.IsDirectory(path) {
+		root = filepath.Dir(path)
+	}
+
+	files, err := sourceFiles(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "porter-build-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &program{
+		dir:        dir,
+		binary:     filepath.Join(dir, "porter-config"),
+		sourceMaps: make(map[string]*t.SourceMap),
+	}
+
+	err = p.writeTree(root, files)
+
+	if err == nil {
+		err = p.writeGoMod(root, frameworkDir)
+	}
+
+	if err == nil {
+		err = p.goCommand("mod", "tidy")
+	}
+
+	if err == nil {
+		err = p.goCommand("build", "-o", p.binary, ".")
+	}
+
+	if err != nil {
+		p.cleanup()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// sourceFiles returns the paths, relative to root, of every file that is part
+// of the build tree. Hidden directories, vendor and testdata are skipped.
+func sourceFiles(root string) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+
+		if info.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if isSourceFile(name) || filepath.Ext(name) == ".go" {
+			rel, err := filepath.Rel(root, path)
+
+			if err != nil {
+				return err
+			}
+
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("no .ego or .go files found in %s", root)
+	}
+
+	return files, err
+}
+
+// isSourceFile returns true if a file is translated to Go
+func isSourceFile(name string) bool {
+	for _, ext := range sourceExts {
+		if filepath.Ext(name) == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeTree writes the build tree to the temporary module, translating each
+// source file to a Go file with the same path and a .go extension appended,
+// and copying each Go file as is. Syntax errors in every file are reported
+// before the build tree is written.
+func (p *program) writeTree(root string, files []string) error {
+	errCount := 0
+	res := make(map[string][]byte)
+
+	for _, rel := range files {
+		filename := filepath.Join(root, rel)
+
+		dat, err := ioutil.ReadFile(filename)
+
+		if err != nil {
+			return fmt.Errorf("error while reading file: %v", err)
+		}
+
+		if !isSourceFile(rel) {
+			res[rel] = dat
+			continue
+		}
+
+		// report every syntax error in the heredocs before translating
+		if err := checkHeredocs(filename, dat); err != nil {
+			fmt.Println(err)
+			errCount++
+			continue
+		}
+
+		// translate the file to Golang, with //line directives that point to
+		// the source file
+		abs, err := filepath.Abs(filename)
+
+		if err != nil {
+			abs = filename
+		}
+
+		trans := t.NewFileTranslator(abs, dat)
+
+		res[rel+".go"] = trans.Translate()
+		p.sourceMaps[rel+".go"] = trans.SourceMap()
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("found syntax errors in %d files", errCount)
+	}
+
+	for rel, dat := range res {
+		dest := filepath.Join(p.dir, rel)
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(dest, dat, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeGoMod writes the go.mod file of the temporary module. If the directory
+// of the main package contains a go.mod file, its module path and requirements
+// are used. Otherwise, the module path is buildModule. The framework is replaced
+// by the local copy in frameworkDir.
+func (p *program) writeGoMod(root string, frameworkDir string) error {
+	var b bytes.Buffer
+
+	if dat, err := ioutil.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+		b.Write(dat)
+
+		// keep the checksums of the requirements
+		if sum, err := ioutil.ReadFile(filepath.Join(root, "go.sum")); err == nil {
+			if err := ioutil.WriteFile(filepath.Join(p.dir, "go.sum"), sum, 0644); err != nil {
+				return err
+			}
+		}
+	} else {
+		fmt.Fprintf(&b, "module %s\n\ngo 1.14\n", buildModule)
+	}
+
+	if !bytes.Contains(b.Bytes(), []byte(frameworkModule+" =>")) {
+		if frameworkDir == "" {
+			frameworkDir = findFrameworkDir(root)
+		}
+
+		if frameworkDir == "" {
+			return fmt.Errorf("could not find the Porter framework, set --framework-dir or $%s to a local copy of %s", frameworkDirEnv, frameworkModule)
+		}
+
+		abs, err := filepath.Abs(frameworkDir)
+
+		if err != nil {
+			return err
+		}
+
+		if !isFrameworkDir(abs) {
+			return fmt.Errorf("framework directory %s does not contain the go.mod file of %s", abs, frameworkModule)
+		}
+
+		fmt.Fprintf(&b, "\nreplace %s => %s\n", frameworkModule, abs)
+	}
+
+	return ioutil.WriteFile(filepath.Join(p.dir, "go.mod"), b.Bytes(), 0644)
+}
+
+// findFrameworkDir returns the root of a local copy of the framework, or an
+// empty string if none is found. The framework is looked for in the parent
+// directories of the program, of the porter executable, and of the source that
+// porter was built from.
+func findFrameworkDir(root string) string {
+	var dirs []string
+
+	if abs, err := filepath.Abs(root); err == nil {
+		dirs = append(dirs, abs)
+	}
+
+	if exe, err := os.Executable(); err == nil {
+		if exe, err := filepath.EvalSymlinks(exe); err == nil {
+			dirs = append(dirs, filepath.Dir(exe))
+		}
+	}
+
+	// the path of this file is only the path of the framework source if porter
+	// was built as the main module of the framework
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Path == frameworkModule {
+		if _, file, _, ok := runtime.Caller(0); ok && filepath.IsAbs(file) {
+			dirs = append(dirs, filepath.Dir(file))
+		}
+	}
+
+	for _, dir := range dirs {
+		for {
+			if isFrameworkDir(dir) {
+				return dir
+			}
+
+			parent := filepath.Dir(dir)
+
+			if parent == dir {
+				break
+			}
+
+			dir = parent
+		}
+	}
+
+	return ""
+}
+
+// isFrameworkDir returns true if dir is the root of the framework module
+func isFrameworkDir(dir string) bool {
+	dat, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(dat), "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`) == frameworkModule
+		}
+	}
+
+	return false
+}
+
+// goCommand runs a go command in the temporary module. If the command fails,
+// its output is returned as an error, with positions in the translated source
+// rewritten to positions in the original source file.
+func (p *program) goCommand(args ...string) error {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = p.dir
+
+	out, err := cmd.CombinedOutput()
+
+	if err != nil {
+		return fmt.Errorf("go %s failed:\n%s", args[0], p.rewrite(string(out)))
+	}
+
+	return nil
+}
+
+// rewrite rewrites positions in the translated files to positions in the
+// source files. Deeper paths are rewritten first, so that a file in the root
+// of the build tree does not match a file with the same name in a subdirectory.
+func (p *program) rewrite(text string) string {
+	var paths []string
+
+	for path := range p.sourceMaps {
+		paths = append(paths, path)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+
+	for _, path := range paths {
+		text = p.sourceMaps[path].Rewrite(path, text)
+	}
+
+	return text
+}
+
+// run executes the built program in the current directory, with env added to
+// its environment, and streams its logs to stdout and stderr. run returns the
+// exit status of the program.
+//
+// The program saves its state when it is interrupted, so porter waits for it to
+// exit instead of exiting on SIGINT or SIGTERM. On Ctrl-C, the terminal sends
+// SIGINT to both porter and the program; SIGTERM is only sent to porter, and is
+// forwarded to the program.
+func (p *program) run(env []string, stdout, stderr io.Writer) (int, error) {
+	cmd := exec.Command(p.binary)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	defer signal.Stop(sigs)
+
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if sig != os.Interrupt {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// the program was terminated by a signal
+		if exitErr.ExitCode() < 0 {
+			return 1, err
+		}
+
+		return exitErr.ExitCode(), nil
+	} else if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+// cleanup removes the temporary module
+func (p *program) cleanup() {
+	os.RemoveAll(p.dir)
+}
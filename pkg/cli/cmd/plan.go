@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/porterdev/ego/pkg/porter"
+)
+
+var (
+	planFlags   programFlags
+	planNoColor bool
+	planOut     string
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Args:  programArgs,
+	Use:   "plan [filename|directory]",
+	Short: "Shows the changes that apply would make, without making them.",
+	Long: `Builds a .ego program like apply, and runs it to retrieve the current data and
+generate the new configuration. Instead of running the operations between them,
+the operations are listed with their old and new values, followed by a summary.
+
+With --out, the plan is also saved to a file, so that it can be reviewed and
+applied later with apply <planfile>.
+
+The exit status is 0 if there are no changes, 2 if there are changes, and 1 if
+the plan failed, so that plan can be used to gate changes in CI.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// the arguments are valid, so errors are not usage errors
+		cmd.SilenceUsage = true
+
+		env := []string{porter.ModeEnv + "=" + porter.ModePlan}
+
+		if planNoColor {
+			env = append(env, "NO_COLOR=1")
+		}
+
+		if planOut != "" {
+			env = append(env, porter.PlanFileEnv+"="+planOut)
+		}
+
+		return programError(runProgram(args[0], planFlags, env...))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	addProgramFlags(planCmd, &planFlags)
+	planCmd.Flags().BoolVar(&planNoColor, "no-color", false, "do not colorize the plan")
+	planCmd.Flags().StringVar(&planOut, "out", "", "path of a file to save the plan to")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -17,12 +18,45 @@ var rootCmd = &cobra.Command{
 using a declarative configuration syntax built on top of Go. 
 
 For more information, visit github.com/porterdev/ego.`,
+
+	// errors are printed by Execute
+	SilenceErrors: true,
+}
+
+// exitError is returned by a command that ran a configuration program which
+// exited with a non-zero status. The program has already reported its errors,
+// so Execute exits with the same status without printing anything.
+type exitError struct {
+	status int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("configuration program exited with status %d", e.status)
+}
+
+// programError returns the error of a command from the exit status of the
+// configuration program that it ran, and the error that prevented it from
+// running, if any
+func programError(status int, err error) error {
+	if err != nil {
+		return err
+	} else if status != 0 {
+		return &exitError{status: status}
+	}
+
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.status)
+		}
+
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/porterdev/ego/pkg/json"
+	"github.com/porterdev/ego/pkg/porter"
+)
+
+var (
+	stateDir       string
+	stateBackupDir string
+
+	pruneKeepLast int
+	pruneMaxAge   time.Duration
+)
+
+// stateCmd represents the state command
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspects and manages the stored state of configurations.",
+}
+
+// backupsCmd represents the state backups command
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Lists, shows, restores and removes backups of the state of a configuration.",
+	Long: `Manages the backups of the state of a configuration. A backup of the state is
+written before each apply, and backups are identified by the time at which they
+were written, as listed by list. Every backup is kept until it is removed with
+prune.`,
+}
+
+// backupsListCmd represents the state backups list command
+var backupsListCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(1),
+	Use:   "list [id]",
+	Short: "Lists the backups of the state of a configuration, most recent first.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		store, err := openStore(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		backups, err := store.GetAllBackups()
+
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 {
+			fmt.Println("No backups.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tSERIAL\tLINEAGE\tCHECKSUM")
+
+		for _, b := range backups {
+			if b.Err != nil {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t%v\n", b.ID, b.Time.Format(time.RFC3339), b.Err)
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", b.ID, b.Time.Format(time.RFC3339), b.Meta.Serial, b.Meta.Lineage, b.Meta.Checksum)
+		}
+
+		return w.Flush()
+	},
+}
+
+// backupsShowCmd represents the state backups show command
+var backupsShowCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "show [id] [backup-id]",
+	Short: "Prints the state stored in a backup as JSON.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		store, err := openStore(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		state, err := store.GetBackup(args[1])
+
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(os.Stdout, json.EncoderOptions{SortKeys: true, Indent: "  "}).Encode(state)
+	},
+}
+
+// backupsRestoreCmd represents the state backups restore command
+var backupsRestoreCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "restore [id] [backup-id]",
+	Short: "Replaces the state of a configuration with a backup.",
+	Long: `Replaces the state of the configuration with the given ID with the state stored
+in a backup. The replaced state is backed up first, so that a restore can be
+undone by restoring that backup. A state that is corrupt is replaced as well.
+
+The state is locked while it is restored, so restore fails if the configuration
+is being applied.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		store, err := openStore(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		if err := store.Lock("restore", 0); err != nil {
+			return err
+		}
+
+		err = store.RestoreBackup(args[1])
+
+		if unlockErr := store.Unlock(); err == nil {
+			err = unlockErr
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored backup %s.\n", args[1])
+
+		return nil
+	},
+}
+
+// backupsPruneCmd represents the state backups prune command
+var backupsPruneCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(1),
+	Use:   "prune [id]",
+	Short: "Removes old backups of the state of a configuration.",
+	Long: `Removes the backups of the state of the configuration with the given ID that are
+not among the --keep-last most recent backups, or that are older than --max-age.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneKeepLast <= 0 && pruneMaxAge <= 0 {
+			return errors.New("prune requires --keep-last or --max-age")
+		}
+
+		cmd.SilenceUsage = true
+
+		store, err := openStore(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		removed, err := store.PruneBackups(porter.BackupRetention{KeepLast: pruneKeepLast, MaxAge: pruneMaxAge})
+
+		fmt.Printf("Removed %d backups.\n", len(removed))
+
+		return err
+	},
+}
+
+// openStore returns the local store of the configuration with an ID
+func openStore(id string) (*porter.LocalStore, error) {
+	backupDir := stateBackupDir
+
+	if backupDir == "" {
+		backupDir = stateDir
+	}
+
+	if !porter.IsDirectory(stateDir) {
+		return nil, fmt.Errorf("state directory %s does not exist", stateDir)
+	} else if !porter.IsDirectory(backupDir) {
+		return nil, fmt.Errorf("backup directory %s does not exist", backupDir)
+	}
+
+	return porter.NewLocalStore(id, porter.NewLogger(porter.ERROR), stateDir, backupDir)
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd, backupsShowCmd, backupsRestoreCmd, backupsPruneCmd)
+
+	stateCmd.PersistentFlags().StringVar(&stateDir, "state-dir", ".", "directory of the state file")
+	stateCmd.PersistentFlags().StringVar(&stateBackupDir, "backup-dir", "", "directory of the backups (default the state directory)")
+
+	backupsPruneCmd.Flags().IntVar(&pruneKeepLast, "keep-last", 0, "number of most recent backups to keep")
+	backupsPruneCmd.Flags().DurationVar(&pruneMaxAge, "max-age", 0, "maximum age of the backups to keep, e.g. 720h")
+}
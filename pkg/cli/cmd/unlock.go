@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/porterdev/ego/pkg/porter"
+)
+
+var unlockStateDir string
+
+// forceUnlockCmd represents the force-unlock command
+var forceUnlockCmd = &cobra.Command{
+	Args:  cobra.ExactArgs(2),
+	Use:   "force-unlock [id] [lock-id]",
+	Short: "Releases the lock on the state of a configuration.",
+	Long: `Releases the lock on the state of the configuration with the given ID, when the
+process that holds it can no longer release it, e.g. after a crash on another
+host. Locks held by processes that are no longer running on the current host
+are released automatically.
+
+The ID of the lock is printed when apply fails to acquire the lock, and is
+required so that a lock acquired in the meantime is not released by mistake.
+Releasing a lock held by a process that is still running can corrupt the state.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		if !porter.IsDirectory(unlockStateDir) {
+			return fmt.Errorf("state directory %s does not exist", unlockStateDir)
+		}
+
+		store, err := porter.NewLocalStore(args[0], porter.NewLogger(porter.ERROR), unlockStateDir, unlockStateDir)
+
+		if err != nil {
+			return err
+		}
+
+		info, err := store.LockInfo()
+
+		if err != nil {
+			return err
+		} else if info == nil {
+			return errors.New("the state is not locked")
+		}
+
+		if err := store.ForceUnlock(args[1]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Released the lock held by %s (process %d on %s) for %s.\n", info.User, info.PID, info.Host, info.Operation)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forceUnlockCmd)
+
+	forceUnlockCmd.Flags().StringVar(&unlockStateDir, "state-dir", ".", "directory of the state file")
+}
@@ -1,92 +1,240 @@
 package json
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	v "github.com/porterdev/ego/internal/value"
 )
 
-// ToJSON converts a Porter value to a JSON string
+// EncoderOptions configures how an Encoder writes values.
+type EncoderOptions struct {
+	// SortKeys writes object keys in sorted order, so that encoding the same
+	// value always produces the same output
+	SortKeys bool
+
+	// Indent is written once per nesting level before each array element and
+	// object key. If Indent is empty, values are written in compact form with
+	// no insignificant whitespace.
+	Indent string
+
+	// EscapeInjections escapes the opening brace of {{ in strings, so that the
+	// output can be read by a Parser in injection mode. Output that is read by
+	// Parse, e.g. state files, does not need to be escaped.
+	EscapeInjections bool
+}
+
+// Encoder writes JSON values to an output stream.
+type Encoder struct {
+	w    *bufio.Writer
+	opts EncoderOptions
+}
+
+// NewEncoder returns a new encoder that writes to w using the given options.
+func NewEncoder(w io.Writer, opts EncoderOptions) *Encoder {
+	return &Encoder{
+		w:    bufio.NewWriter(w),
+		opts: opts,
+	}
+}
+
+// Encode writes the JSON encoding of a Porter value to the stream, followed
+// by a newline character.
+func (e *Encoder) Encode(v1 v.Value) error {
+	err := encode(e.w, v1, e.opts, 0)
+
+	if err != nil {
+		return err
+	}
+
+	err = e.w.WriteByte('\n')
+
+	if err != nil {
+		return err
+	}
+
+	return e.w.Flush()
+}
+
+// ToJSON converts a Porter value to a compact JSON string. Object keys are
+// sorted, so the same value always produces the same string.
 func ToJSON(v1 v.Value) (string, error) {
+	var b strings.Builder
+
+	w := bufio.NewWriter(&b)
+
+	err := encode(w, v1, EncoderOptions{SortKeys: true}, 0)
+
+	if err != nil {
+		return "", err
+	}
+
+	w.Flush()
+
+	return b.String(), nil
+}
+
+// encode writes a Porter value to w, where depth is the current nesting level
+// used for indentation
+func encode(w *bufio.Writer, v1 v.Value, opts EncoderOptions, depth int) error {
 	if v1 == nil {
-		return "null", nil
+		_, err := w.WriteString("null")
+		return err
 	}
 
 	switch v1.(type) {
 	case v.Boolean:
 		res, _ := v1.(v.Boolean)
 
-		return strconv.FormatBool(bool(res)), nil
+		_, err := w.WriteString(strconv.FormatBool(bool(res)))
+		return err
 	case v.Float:
 		res, _ := v1.(v.Float)
 
-		return strconv.FormatFloat(float64(res), 'e', -1, 64), nil
+		_, err := w.WriteString(strconv.FormatFloat(float64(res), 'e', -1, 64))
+		return err
 	case v.Integer:
 		res, _ := v1.(v.Integer)
 
-		return strconv.FormatInt(int64(res), 10), nil
+		_, err := w.WriteString(strconv.FormatInt(int64(res), 10))
+		return err
 	case v.String:
 		res, _ := v1.(v.String)
 
-		return "\"" + string(res) + "\"", nil
+		_, err := w.WriteString(quote(string(res), opts.EscapeInjections))
+		return err
 	case v.Array:
 		res, _ := v1.(v.Array)
 
-		str := "["
+		w.WriteByte('[')
 
 		for i, val := range res {
-			valStr, err := ToJSON(val)
-
-			if err != nil {
-				return "", err
+			if i > 0 {
+				w.WriteByte(',')
 			}
 
-			str += valStr
+			writeIndent(w, opts, depth+1)
+
+			err := encode(w, val, opts, depth+1)
 
-			if i+1 < len(res) {
-				str += ","
+			if err != nil {
+				return err
 			}
 		}
 
-		str += "]"
+		if len(res) > 0 {
+			writeIndent(w, opts, depth)
+		}
 
-		return str, nil
+		return w.WriteByte(']')
 	case v.Object:
 		res, _ := v1.(v.Object)
 
-		str := "{"
+		keys := make([]string, 0, len(res))
 
-		count := 0
+		for k := range res {
+			keys = append(keys, string(k))
+		}
 
-		for k, v := range res {
-			keyStr, keyErr := ToJSON(k)
+		if opts.SortKeys {
+			sort.Strings(keys)
+		}
+
+		w.WriteByte('{')
 
-			if keyErr != nil {
-				return "", keyErr
+		for i, k := range keys {
+			if i > 0 {
+				w.WriteByte(',')
 			}
 
-			str += keyStr + ":"
+			writeIndent(w, opts, depth+1)
 
-			valStr, valErr := ToJSON(v)
+			w.WriteString(quote(k, opts.EscapeInjections))
+			w.WriteByte(':')
 
-			if valErr != nil {
-				return "", valErr
+			if opts.Indent != "" {
+				w.WriteByte(' ')
 			}
 
-			str += valStr
+			err := encode(w, res[v.String(k)], opts, depth+1)
 
-			if count+1 < len(res) {
-				str += ","
+			if err != nil {
+				return err
 			}
+		}
 
-			count++
+		if len(res) > 0 {
+			writeIndent(w, opts, depth)
 		}
 
-		str += "}"
+		return w.WriteByte('}')
+	}
+
+	return fmt.Errorf("Value does not contain a supported Porter type")
+}
+
+// writeIndent starts a new line at the given nesting level. In compact mode,
+// writeIndent writes nothing.
+func writeIndent(w *bufio.Writer, opts EncoderOptions, depth int) {
+	if opts.Indent == "" {
+		return
+	}
+
+	w.WriteByte('\n')
 
-		return str, nil
+	for i := 0; i < depth; i++ {
+		w.WriteString(opts.Indent)
 	}
+}
+
+// quote converts a Go string to a JSON string literal, escaping quotes,
+// backslashes and control characters as required by RFC 8259. Invalid UTF-8
+// is written as the unicode replacement character. If escapeInjections is
+// true, the opening brace of {{ is escaped so that the string does not contain
+// an injection.
+func quote(str string, escapeInjections bool) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+
+	for i := 0; i < len(str); {
+		r, size := utf8.DecodeRuneInString(str[i:])
+
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case escapeInjections && r == '{' && strings.HasPrefix(str[i+1:], "{"):
+			// escape {{ so that it is not parsed as an injection
+			b.WriteString(`\u007b`)
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`\ufffd`)
+		default:
+			b.WriteString(str[i : i+size])
+		}
+
+		i += size
+	}
+
+	b.WriteByte('"')
 
-	return "", fmt.Errorf("Value does not contain a supported Porter type")
+	return b.String()
 }
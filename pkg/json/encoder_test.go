@@ -1,7 +1,9 @@
 package json
 
 import (
+	"bytes"
 	"testing"
+	"testing/quick"
 
 	v "github.com/porterdev/ego/internal/value"
 )
@@ -133,7 +135,7 @@ var encoderTestsObjectPass = []encoderTest{
 			},
 			v.String("!"): v.Object{},
 		},
-		want: "{\"hello\":{\"there\":{\"general\":\"kenobi\"}},\"!\":{}}",
+		want: "{\"!\":{},\"hello\":{\"there\":{\"general\":\"kenobi\"}}}",
 	},
 }
 
@@ -170,3 +172,240 @@ func TestEncoderStructurePass(t *testing.T) {
 		}
 	}
 }
+
+type encoderOptionsTest struct {
+	name string
+	opts EncoderOptions
+	val  v.Value
+	want string
+}
+
+var encoderOptionsTestVal = v.Object{
+	v.String("spec"): v.Object{
+		v.String("replicas"): v.Integer(3),
+		v.String("ports"): v.Array{
+			v.Integer(80),
+			v.Integer(443),
+		},
+		v.String("empty"): v.Array{},
+	},
+	v.String("kind"):     v.String("Deployment"),
+	v.String("metadata"): v.Object{},
+}
+
+var encoderTestsOptionsPass = []encoderOptionsTest{
+	encoderOptionsTest{
+		name: "Options: compact sorted",
+		opts: EncoderOptions{SortKeys: true},
+		val:  encoderOptionsTestVal,
+		want: "{\"kind\":\"Deployment\",\"metadata\":{},\"spec\":{\"empty\":[],\"ports\":[80,443],\"replicas\":3}}\n",
+	},
+	encoderOptionsTest{
+		name: "Options: indented sorted",
+		opts: EncoderOptions{SortKeys: true, Indent: "  "},
+		val:  encoderOptionsTestVal,
+		want: `{
+  "kind": "Deployment",
+  "metadata": {},
+  "spec": {
+    "empty": [],
+    "ports": [
+      80,
+      443
+    ],
+    "replicas": 3
+  }
+}
+`,
+	},
+	encoderOptionsTest{
+		name: "Options: indented with tabs",
+		opts: EncoderOptions{SortKeys: true, Indent: "\t"},
+		val: v.Array{
+			v.Object{
+				v.String("b"): nil,
+				v.String("a"): v.Boolean(true),
+			},
+		},
+		want: "[\n\t{\n\t\t\"a\": true,\n\t\t\"b\": null\n\t}\n]\n",
+	},
+	encoderOptionsTest{
+		name: "Options: indented literal",
+		opts: EncoderOptions{Indent: "  "},
+		val:  v.String("hello"),
+		want: "\"hello\"\n",
+	},
+}
+
+func TestEncoderOptionsPass(t *testing.T) {
+	for _, c := range encoderTestsOptionsPass {
+		var buf bytes.Buffer
+
+		err := NewEncoder(&buf, c.opts).Encode(c.val)
+
+		if err != nil || buf.String() != c.want {
+			t.Errorf("Failed on: %s, %v, %q, %q", c.name, err, buf.String(), c.want)
+		}
+	}
+}
+
+func TestEncoderStable(t *testing.T) {
+	val := v.Object{}
+
+	for _, k := range []string{"q", "w", "e", "r", "t", "y", "u", "i", "o", "p"} {
+		val[v.String(k)] = v.Object{
+			v.String(k + "1"): v.Integer(1),
+			v.String(k + "2"): v.Integer(2),
+		}
+	}
+
+	var want bytes.Buffer
+
+	NewEncoder(&want, EncoderOptions{SortKeys: true, Indent: "  "}).Encode(val)
+
+	for i := 0; i < 20; i++ {
+		var got bytes.Buffer
+
+		NewEncoder(&got, EncoderOptions{SortKeys: true, Indent: "  "}).Encode(val)
+
+		if got.String() != want.String() {
+			t.Fatalf("Encoding is not stable: %s, %s", got.String(), want.String())
+		}
+	}
+}
+
+func TestEncoderStream(t *testing.T) {
+	var buf bytes.Buffer
+
+	enc := NewEncoder(&buf, EncoderOptions{SortKeys: true})
+
+	for _, val := range []v.Value{v.Integer(1), v.String("two"), v.Array{}} {
+		if err := enc.Encode(val); err != nil {
+			t.Errorf("Failed on: %v, %v", val, err)
+		}
+	}
+
+	want := "1\n\"two\"\n[]\n"
+
+	if buf.String() != want {
+		t.Errorf("Failed on stream: %q, %q", buf.String(), want)
+	}
+}
+
+func TestEncoderUnsupportedType(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := NewEncoder(&buf, EncoderOptions{}).Encode(v.Array{"not a porter string"})
+
+	if err == nil {
+		t.Errorf("Expected error encoding unsupported type, got %q", buf.String())
+	}
+}
+
+var encoderTestsStringPass = []encoderTest{
+	encoderTest{
+		name: "String: quotes and backslashes",
+		val:  v.String("say \"hi\" \\o/"),
+		want: "\"say \\\"hi\\\" \\\\o/\"",
+	},
+	encoderTest{
+		name: "String: whitespace escapes",
+		val:  v.String("a\nb\tc\rd\be\ff"),
+		want: "\"a\\nb\\tc\\rd\\be\\ff\"",
+	},
+	encoderTest{
+		name: "String: control characters",
+		val:  v.String("\x00\x1f"),
+		want: "\"\\u0000\\u001f\"",
+	},
+	encoderTest{
+		name: "String: non-ascii is not escaped",
+		val:  v.String("h\u00e9llo \U0001F600"),
+		want: "\"h\u00e9llo \U0001F600\"",
+	},
+	encoderTest{
+		name: "String: invalid utf-8",
+		val:  v.String("a\xffb"),
+		want: "\"a\\ufffdb\"",
+	},
+	encoderTest{
+		name: "String: injection braces",
+		val:  v.String("{{x}} {y} {{{"),
+		want: "\"{{x}} {y} {{{\"",
+	},
+	encoderTest{
+		name: "String: escaped object key",
+		val: v.Object{
+			v.String("a\"b"): v.String("c\nd"),
+		},
+		want: "{\"a\\\"b\":\"c\\nd\"}",
+	},
+}
+
+func TestEncoderStringPass(t *testing.T) {
+	for _, c := range encoderTestsStringPass {
+		got, _ := ToJSON(c.val)
+
+		if got != c.want {
+			t.Errorf("Failed on: %s, %v, %s, %s", c.name, c.val, got, c.want)
+		}
+	}
+}
+
+func TestEncoderEscapeInjections(t *testing.T) {
+	var buf bytes.Buffer
+
+	val := v.Object{
+		v.String("{{k}}"): v.String("{{x}} {y} {{{"),
+	}
+
+	NewEncoder(&buf, EncoderOptions{EscapeInjections: true}).Encode(val)
+
+	if want := "{\"\\u007b{k}}\":\"\\u007b{x}} {y} \\u007b\\u007b{\"}\n"; buf.String() != want {
+		t.Errorf("Failed on: escaped injection braces, %s, %s", buf.String(), want)
+	}
+}
+
+func TestEncoderStringRoundTrip(t *testing.T) {
+	roundTrip := func(str string) bool {
+		val := v.Object{
+			v.String(str): v.Array{
+				v.String(str),
+			},
+		}
+
+		enc, err := ToJSON(val)
+
+		if err != nil {
+			return false
+		}
+
+		res, err := Parse([]byte(enc))
+
+		if err != nil || !v.IsEqual(res, val) {
+			return false
+		}
+
+		// escaped output can be read in injection mode
+		var buf bytes.Buffer
+
+		if err := NewEncoder(&buf, EncoderOptions{EscapeInjections: true}).Encode(val); err != nil {
+			return false
+		}
+
+		p := NewParser(buf.Bytes())
+		res, err = p.Parse()
+
+		return err == nil && v.IsEqual(res, val)
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Error(err)
+	}
+
+	for _, str := range []string{"", "\"", "\\", "\\u0041", "\u2028\u2029", "\x7f", "\x00\x01\x02", "{{x}}", "{{{"} {
+		if !roundTrip(str) {
+			t.Errorf("Failed round trip on: %q", str)
+		}
+	}
+}
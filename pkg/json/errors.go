@@ -0,0 +1,131 @@
+package json
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError describes a syntax error in a JSON document: where it was found,
+// the token that caused it and the tokens that would have been accepted.
+type SyntaxError struct {
+	Pos      Position // position where the error was detected
+	Token    Token    // offending token, or ILLEGAL if the token could not be scanned
+	Lit      string   // literal value of the offending token
+	TokenPos Position // position of the offending token
+	Expected []Token  // tokens that would have been valid, if known
+	Msg      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("Column %d, Line %d: %s", e.Pos.Column, e.Pos.Line, e.Msg)
+}
+
+// Underline returns the line of src that contains the offending token,
+// followed by a line of carets underlining the token.
+func (e *SyntaxError) Underline(src []byte) string {
+	pos := e.TokenPos
+
+	if pos.Line == 0 {
+		pos = e.Pos
+	}
+
+	lines := strings.Split(string(src), "\n")
+
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+
+	var carets strings.Builder
+
+	// preserve tabs so that the carets line up with the source line
+	col := 1
+
+	for _, r := range line {
+		if col >= pos.Column {
+			break
+		}
+
+		if r == '\t' {
+			carets.WriteByte('\t')
+		} else {
+			carets.WriteByte(' ')
+		}
+
+		col++
+	}
+
+	for ; col < pos.Column; col++ {
+		carets.WriteByte(' ')
+	}
+
+	n := utf8.RuneCountInString(e.Lit)
+
+	if e.Token == STRING || n == 0 {
+		n = 1
+	}
+
+	carets.WriteString(strings.Repeat("^", n))
+
+	return line + "\n" + carets.String()
+}
+
+// ErrorList is a list of syntax errors, sorted by position by the parser.
+type ErrorList []*SyntaxError
+
+// add appends an error to the list, skipping errors reported at the same
+// position as the previous error
+func (l *ErrorList) add(err error) {
+	synErr, ok := err.(*SyntaxError)
+
+	if !ok {
+		synErr = &SyntaxError{Token: ILLEGAL, Msg: err.Error()}
+	}
+
+	if n := len(*l); n > 0 && (*l)[n-1].Pos == synErr.Pos {
+		return
+	}
+
+	*l = append(*l, synErr)
+}
+
+// Len is the number of errors in the list.
+func (l ErrorList) Len() int { return len(l) }
+
+// Swap swaps two errors in the list.
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// Less orders errors by line, then column.
+func (l ErrorList) Less(i, j int) bool {
+	if l[i].Pos.Line != l[j].Pos.Line {
+		return l[i].Pos.Line < l[j].Pos.Line
+	}
+
+	return l[i].Pos.Column < l[j].Pos.Column
+}
+
+// Error returns the first error in the list and the number of other errors.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0].Error(), len(l)-1)
+}
+
+// Err returns an error equivalent to the list, or nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	sort.Stable(l)
+
+	return l
+}
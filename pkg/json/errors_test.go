@@ -0,0 +1,284 @@
+package json
+
+import (
+	"testing"
+)
+
+type syntaxErrorTest struct {
+	name     string
+	json     string
+	pos      Position
+	tok      Token
+	expected []Token
+}
+
+var syntaxErrorTests = []syntaxErrorTest{
+	syntaxErrorTest{
+		name:     "Syntax error: missing colon",
+		json:     "{\"x\", null}",
+		pos:      Position{Offset: 5, Line: 1, Column: 6},
+		tok:      COMMA,
+		expected: []Token{COLON},
+	},
+	syntaxErrorTest{
+		name:     "Syntax error: missing comma in array",
+		json:     "[1 2]",
+		pos:      Position{Offset: 4, Line: 1, Column: 5},
+		tok:      NUMBER,
+		expected: []Token{COMMA, RBRACK},
+	},
+	syntaxErrorTest{
+		name:     "Syntax error: garbage after object value",
+		json:     "{\"a\":\"a\" 123}",
+		pos:      Position{Offset: 12, Line: 1, Column: 13},
+		tok:      NUMBER,
+		expected: []Token{COMMA, RBRACE},
+	},
+	syntaxErrorTest{
+		name:     "Syntax error: illegal token",
+		json:     "['a']",
+		pos:      Position{Offset: 1, Line: 1, Column: 2},
+		tok:      ILLEGAL,
+		expected: nil,
+	},
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, c := range syntaxErrorTests {
+		p := NewParser([]byte(c.json))
+
+		_, err := p.Parse()
+
+		synErr, ok := err.(*SyntaxError)
+
+		if !ok {
+			t.Errorf("Failed on: %s, expected *SyntaxError, got %T", c.name, err)
+			continue
+		}
+
+		if synErr.Pos != c.pos {
+			t.Errorf("Failed on: %s, position: expected %v, got %v", c.name, c.pos, synErr.Pos)
+		}
+
+		if synErr.Token != c.tok {
+			t.Errorf("Failed on: %s, token: expected %v, got %v", c.name, c.tok, synErr.Token)
+		}
+
+		if len(synErr.Expected) != len(c.expected) {
+			t.Errorf("Failed on: %s, expected tokens: expected %v, got %v", c.name, c.expected, synErr.Expected)
+			continue
+		}
+
+		for i := range c.expected {
+			if synErr.Expected[i] != c.expected[i] {
+				t.Errorf("Failed on: %s, expected tokens: expected %v, got %v", c.name, c.expected, synErr.Expected)
+			}
+		}
+	}
+}
+
+type underlineTest struct {
+	name string
+	json string
+	want string
+}
+
+var underlineTests = []underlineTest{
+	underlineTest{
+		name: "Underline: missing colon",
+		json: "{\n\t\"x\", null\n}",
+		want: "\t\"x\", null\n\t   ^",
+	},
+	underlineTest{
+		name: "Underline: multi-character token",
+		json: "[\n  1,\n  2 true\n]",
+		want: "  2 true\n    ^^^^",
+	},
+	underlineTest{
+		name: "Underline: scanner error",
+		json: "[tru]",
+		want: "[tru]\n ^^^",
+	},
+}
+
+func TestUnderline(t *testing.T) {
+	for _, c := range underlineTests {
+		p := NewParser([]byte(c.json))
+
+		_, err := p.Parse()
+
+		synErr, ok := err.(*SyntaxError)
+
+		if !ok {
+			t.Errorf("Failed on: %s, expected *SyntaxError, got %v", c.name, err)
+			continue
+		}
+
+		if got := synErr.Underline([]byte(c.json)); got != c.want {
+			t.Errorf("Failed on: %s, expected\n%s\ngot\n%s", c.name, c.want, got)
+		}
+	}
+}
+
+type allErrorsTest struct {
+	name string
+	json string
+	msgs []string
+}
+
+var allErrorsTests = []allErrorsTest{
+	allErrorsTest{
+		name: "All errors: valid document",
+		json: "{\"a\": [1, 2, {\"b\": null}]}",
+		msgs: []string{},
+	},
+	allErrorsTest{
+		name: "All errors: errors in several values",
+		json: "{\n\"a\": tru,\n\"b\": [1 2],\n\"c\" 3,\n\"d\": 'x'\n}",
+		msgs: []string{
+			"Column 9, Line 2: Not a valid name token: must be true, false, or null. Strings must be enclosed in quotes",
+			"Column 10, Line 3: Values must be separated by a comma",
+			"Column 6, Line 4: Must use colon : to define a string: value pair",
+			"Column 6, Line 5: Illegal token",
+		},
+	},
+	allErrorsTest{
+		name: "All errors: trailing commas",
+		json: "[[1,],{\"a\":1,},]",
+		msgs: []string{
+			"Column 6, Line 1: Commas must be followed by a value",
+			"Column 15, Line 1: Commas must be followed by a string: value pair",
+			"Column 17, Line 1: Commas must be followed by a value",
+		},
+	},
+	allErrorsTest{
+		name: "All errors: missing commas between pairs",
+		json: "{\"a\":[1] \"b\":{} \"c\" 3, \"d\":true}",
+		msgs: []string{
+			"Column 13, Line 1: Values must be separated by a comma",
+			"Column 20, Line 1: Values must be separated by a comma",
+			"Column 22, Line 1: Must use colon : to define a string: value pair",
+		},
+	},
+	allErrorsTest{
+		name: "All errors: bad escape and bad number",
+		json: "[\"\\x\", 012, 3]",
+		msgs: []string{
+			"Column 4, Line 1: Invalid escape sequence",
+			"Column 8, Line 1: 0 cannot be followed by digit without . or exponent",
+		},
+	},
+	allErrorsTest{
+		name: "All errors: unclosed containers",
+		json: "[{\"a\": [1",
+		msgs: []string{
+			"Column 10, Line 1: No closing bracket in array",
+		},
+	},
+	allErrorsTest{
+		name: "All errors: garbage after document",
+		json: "[1] 2",
+		msgs: []string{
+			"Column 6, Line 1: Illegal token",
+		},
+	},
+}
+
+func TestAllErrors(t *testing.T) {
+	for _, c := range allErrorsTests {
+		p := NewParserMode([]byte(c.json), AllErrors)
+
+		_, err := p.Parse()
+
+		if len(c.msgs) == 0 {
+			if err != nil {
+				t.Errorf("Failed on: %s, expected no errors, got %v", c.name, err)
+			}
+
+			continue
+		}
+
+		list, ok := err.(ErrorList)
+
+		if !ok {
+			t.Errorf("Failed on: %s, expected ErrorList, got %T", c.name, err)
+			continue
+		}
+
+		if len(list) != len(c.msgs) {
+			t.Errorf("Failed on: %s, expected %d errors, got %d: %v", c.name, len(c.msgs), len(list), list)
+			continue
+		}
+
+		for i, msg := range c.msgs {
+			if list[i].Error() != msg {
+				t.Errorf("Failed on: %s, error %d: expected %s, got %s", c.name, i, msg, list[i].Error())
+			}
+		}
+	}
+}
+
+// Every document that fails to parse should report its first error in AllErrors
+// mode, and parsing must terminate.
+func TestAllErrorsContainsFirstError(t *testing.T) {
+	var cases []jsonTestFail
+
+	cases = append(cases, jsonTestsArrayFail...)
+	cases = append(cases, jsonTestsIncompleteFail...)
+	cases = append(cases, jsonTestsNumberFail...)
+	cases = append(cases, jsonTestsObjectFail...)
+	cases = append(cases, jsonTestsStructureFail...)
+	cases = append(cases, jsonTestsStringFail...)
+
+	for _, c := range cases {
+		p := NewParserMode([]byte(c.json), AllErrors)
+
+		_, err := p.Parse()
+
+		list, ok := err.(ErrorList)
+
+		if !ok || len(list) == 0 {
+			t.Errorf("Failed on: %s, expected errors, got %v", c.name, err)
+			continue
+		}
+
+		found := false
+
+		for _, e := range list {
+			found = found || e.Error() == c.msg
+		}
+
+		if !found {
+			t.Errorf("Failed on: %s, expected %s in %v", c.name, c.msg, list)
+		}
+	}
+}
+
+func TestCheckInjections(t *testing.T) {
+	errs := Check([]byte("{\n  {{name}}: {{value}},\n  \"list\": [{{a}} {{b}}]\n}"))
+
+	if len(errs) != 1 || errs[0].Error() != "Column 20, Line 3: Values must be separated by a comma" {
+		t.Errorf("Failed on check with injections: %v", errs)
+	}
+
+	if errs := Check([]byte("{ {{key}}: [1, {{val}}] }")); len(errs) != 0 {
+		t.Errorf("Failed on valid check with injections: %v", errs)
+	}
+}
+
+func TestErrorListError(t *testing.T) {
+	list := ErrorList{
+		&SyntaxError{Pos: Position{Line: 2, Column: 1}, Msg: "second"},
+		&SyntaxError{Pos: Position{Line: 1, Column: 4}, Msg: "first"},
+	}
+
+	err := list.Err()
+
+	if err == nil || err.Error() != "Column 4, Line 1: first (and 1 more errors)" {
+		t.Errorf("Failed on error list: %v", err)
+	}
+
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("Expected empty error list to be nil")
+	}
+}
@@ -1,12 +1,55 @@
 package json
 
 import (
-	"fmt"
 	"strconv"
+	"strings"
 
+	"github.com/porterdev/ego/internal/inject"
 	v "github.com/porterdev/ego/internal/value"
 )
 
+// Mode is a set of flags that control optional parser behavior.
+type Mode uint
+
+const (
+	// AllErrors makes the parser recover from syntax errors and report every
+	// error in the document as an ErrorList, instead of stopping at the first.
+	AllErrors Mode = 1 << iota
+
+	// IgnoreInjections parses every injection as an empty string, so that a
+	// document can be checked before the injected values are known.
+	IgnoreInjections
+
+	// NoInjections parses the document as plain JSON, in which {{ and }} are
+	// braces or text inside of strings rather than injections.
+	NoInjections
+
+	// KeepFloats parses numbers with a fraction or an exponent as a Float, even
+	// if they are integral, instead of converting them to an Integer.
+	KeepFloats
+)
+
+// valueTokens is the set of tokens that can start a value
+var valueTokens = []Token{STRING, NUMBER, TRUE, FALSE, NULL, LBRACE, LBRACK, LINJECT}
+
+// Parse creates a Porter Value from a plain JSON document, such as a document
+// written by an Encoder. The document has no injections, so {{ inside of a
+// string is kept as is.
+func Parse(src []byte) (v.Value, error) {
+	p := NewParserMode(src, NoInjections)
+
+	return p.Parse()
+}
+
+// ParseExact creates a Porter Value from a plain JSON document written by an
+// Encoder, like Parse, but keeps integral floats as a Float, so that every
+// value decodes to the value that was encoded.
+func ParseExact(src []byte) (v.Value, error) {
+	p := NewParserMode(src, NoInjections|KeepFloats)
+
+	return p.Parse()
+}
+
 // Inject takes in an array of bytes that contains Porter injection syntax {{}}
 // and creates a Porter Value using those injected variables
 func Inject(src string, v ...v.Value) (v.Value, error) {
@@ -17,6 +60,38 @@ func Inject(src string, v ...v.Value) (v.Value, error) {
 	return p.Parse()
 }
 
+// Bindings maps the code inside each injection {{}} to the value injected in
+// its place, see inject.Bindings
+type Bindings = inject.Bindings
+
+// InjectNamed takes in a string that contains Porter injection syntax {{}} and
+// creates a Porter Value, binding each injection to a value by its name. It is
+// an error for an injection to have no binding, or for a binding to be unused.
+func InjectNamed(src string, b Bindings) (v.Value, error) {
+	return inject.Named(b, func(resolve func(name string) (v.Value, error)) (v.Value, error) {
+		return InjectFunc(src, resolve)
+	})
+}
+
+// InjectFunc takes in a string that contains Porter injection syntax {{}} and
+// creates a Porter Value, calling resolve with the name of each injection to
+// get its value
+func InjectFunc(src string, resolve func(name string) (v.Value, error)) (v.Value, error) {
+	p := NewParser([]byte(src))
+	p.resolve = resolve
+
+	return p.Parse()
+}
+
+// Check parses a document that may contain injections, and returns every
+// syntax error found in it.
+func Check(src []byte) ErrorList {
+	p := NewParserMode(src, AllErrors|IgnoreInjections)
+	p.Parse()
+
+	return p.errors
+}
+
 // Parser type holds parser's internal state
 type Parser struct {
 	scanner Scanner
@@ -27,16 +102,36 @@ type Parser struct {
 	// store injection variables
 	injections v.Array
 	currInj    int
+
+	// resolve injection variables by name, if set
+	resolve func(name string) (v.Value, error)
+
+	mode   Mode
+	errors ErrorList
 }
 
 // NewParser returns a new parser based on a set of input bytes
 func NewParser(src []byte) (p Parser) {
+	return NewParserMode(src, 0)
+}
+
+// NewParserMode returns a new parser based on a set of input bytes, using the
+// optional behavior set in mode
+func NewParserMode(src []byte, mode Mode) (p Parser) {
+	s := NewScanner(src)
+	s.plain = mode&NoInjections != 0
+
 	return Parser{
-		scanner: *NewScanner(src),
+		scanner: *s,
+		mode:    mode,
 	}
 }
 
-// Parse returns a Value representing the JSON object in Dynamic Syntax form
+// Parse returns a Value representing the JSON object in Dynamic Syntax form.
+//
+// Errors are returned as a *SyntaxError. If the parser was created with the
+// AllErrors mode, Parse returns the best-effort Value and an ErrorList
+// containing every error in the document.
 func (p *Parser) Parse() (v.Value, error) {
 	err := p.next()
 
@@ -48,31 +143,137 @@ func (p *Parser) Parse() (v.Value, error) {
 
 	val, err = p.parseValue()
 
+	if err != nil {
+		if p.mode&AllErrors == 0 {
+			return val, err
+		}
+
+		p.errors.add(err)
+		p.skip()
+	}
+
 	// advance to hopefully the end of parsing (EOF)
-	p.next()
+	if p.richTok.tok != EOF {
+		p.next()
+	}
+
+	if p.richTok.tok != EOF && p.richTok.tok != ILLEGAL {
+		err = p.error("Illegal token", EOF)
+
+		if p.mode&AllErrors == 0 {
+			return nil, err
+		}
 
-	if err == nil && p.richTok.tok != EOF {
-		return nil, fmt.Errorf("Column %d, Line %d: Illegal token",
-			p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+		p.errors.add(err)
 	}
 
-	return val, err
+	return val, p.errors.Err()
 }
 
 // ----------------------------------------------------------------------------
 // Parser helper methods
 func (p *Parser) next() error {
+	offs := p.scanner.srcPos.Offset
+
 	val, err := p.scanner.Scan()
 	p.richTok = val
 
+	if err != nil && p.mode&AllErrors != 0 {
+		// record the error and continue with an ILLEGAL token in place of the
+		// token that could not be scanned
+		p.errors.add(err)
+
+		pos := p.scanner.srcPos
+
+		if synErr, ok := err.(*SyntaxError); ok {
+			pos = synErr.TokenPos
+		}
+
+		if p.scanner.srcPos.Offset == offs && p.scanner.ch != eof {
+			p.scanner.next()
+		}
+
+		p.scanner.skipIllegal()
+
+		p.richTok = RichToken{
+			pos: pos,
+			tok: ILLEGAL,
+		}
+
+		return nil
+	}
+
 	return err
 }
 
+// error creates a syntax error for the current token, listing the tokens that
+// would have been valid in its place
+func (p *Parser) error(msg string, expected ...Token) *SyntaxError {
+	return &SyntaxError{
+		Pos:      p.scanner.srcPos,
+		Token:    p.richTok.tok,
+		Lit:      p.richTok.lit,
+		TokenPos: p.richTok.pos,
+		Expected: expected,
+		Msg:      msg,
+	}
+}
+
+// report records err if the parser reports all errors, so that parsing can
+// continue at the current token. Otherwise, report returns err.
+func (p *Parser) report(err error) error {
+	if p.mode&AllErrors == 0 {
+		return err
+	}
+
+	p.errors.add(err)
+
+	return nil
+}
+
+// sync records err if the parser reports all errors, and skips to the next
+// comma or closing token that is not nested inside another container, so that
+// parsing can continue. Otherwise, sync returns err.
+func (p *Parser) sync(err error) error {
+	if err = p.report(err); err != nil {
+		return err
+	}
+
+	p.skip()
+
+	return nil
+}
+
+// skip advances the parser to the next comma, closing token or EOF that is
+// not nested inside another container
+func (p *Parser) skip() {
+	depth := 0
+
+	for {
+		switch tok := p.richTok.tok; {
+		case tok == EOF:
+			return
+		case tok == LBRACE || tok == LBRACK:
+			depth++
+		case tok == RBRACE || tok == RBRACK:
+			if depth == 0 {
+				return
+			}
+
+			depth--
+		case tok == COMMA && depth == 0:
+			return
+		}
+
+		p.next()
+	}
+}
+
 func (p *Parser) parseValue() (v.Value, error) {
 	switch tok := p.richTok.tok; {
 	case tok.IsLiteral():
 		if tok == STRING {
-			return v.String(p.richTok.lit), nil
+			return p.parseString()
 		} else if tok == NUMBER {
 			return p.parseNumber()
 		} else if tok == TRUE {
@@ -87,27 +288,23 @@ func (p *Parser) parseValue() (v.Value, error) {
 			return p.parseObject()
 		} else if tok == RBRACE {
 			// this should have occurred in parseObject
-			return nil, fmt.Errorf("Column %d, Line %d: Right brace } not preceded by left brace {",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Right brace } not preceded by left brace {", valueTokens...)
 		} else if tok == LBRACK {
 			return p.parseArray()
 		} else if tok == RBRACK {
-			return nil, fmt.Errorf("Column %d, Line %d: Right bracket ] not preceded by left bracket [",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Right bracket ] not preceded by left bracket [", valueTokens...)
 		} else if tok == COMMA {
-			return nil, fmt.Errorf("Column %d, Line %d: Commas can only be present when separating array or object values",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Commas can only be present when separating array or object values", valueTokens...)
 		} else if tok == COLON {
-			return nil, fmt.Errorf("Column %d, Line %d: Colons can only be used for string: value pairs inside objects",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Colons can only be used for string: value pairs inside objects", valueTokens...)
 		} else if tok == LINJECT {
 			return p.parseInjection()
 		} else if tok == RINJECT {
-			return nil, fmt.Errorf("Column %d, Line %d: Right injection }} not preceded by left injection {{",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Right injection }} not preceded by left injection {{", valueTokens...)
 		}
 	}
 
+	// EOF, or an ILLEGAL token that has already been reported
 	return nil, nil
 }
 
@@ -128,63 +325,121 @@ func (p *Parser) parseObject() (v.Value, error) {
 
 			richTok := p.richTok
 
-			// if an injection, parse and rewrite richTok
-			if richTok.tok == LINJECT {
-				inj, err := p.parseInjection()
-
-				if err != nil {
+			if (richTok.tok == STRING || richTok.tok == LINJECT) && prevTok != LBRACE && prevTok != COMMA && prevTok != ILLEGAL {
+				// recover as if the comma were present
+				if err = p.report(p.error("Values must be separated by a comma", COMMA, RBRACE)); err != nil {
 					return nil, err
 				}
+			}
+
+			// if an injection or a string containing injections, parse and
+			// rewrite richTok
+			if richTok.tok == LINJECT || (richTok.tok == STRING && richTok.parts != nil) {
+				var key v.Value
 
-				// verify value is a string
-				str, ok := inj.(v.String)
+				if richTok.tok == LINJECT {
+					key, err = p.parseInjection()
+				} else {
+					key, err = p.parseString()
+				}
 
-				if !ok {
-					return nil, fmt.Errorf("Column %d, Line %d: Key must be a string for a key injection",
-						p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				if err == nil {
+					// verify value is a string
+					if str, ok := key.(v.String); ok {
+						richTok = RichToken{
+							tok: STRING,
+							pos: p.scanner.srcPos,
+							lit: string(str),
+						}
+					} else {
+						err = p.error("Key must be a string for a key injection", STRING)
+					}
 				}
 
-				richTok = RichToken{
-					tok: STRING,
-					pos: p.scanner.srcPos,
-					lit: string(str),
+				if err != nil {
+					if err = p.sync(err); err != nil {
+						return nil, err
+					} else if p.richTok.tok != COMMA {
+						return obj, nil
+					}
+
+					prevTok = COMMA
+					continue
 				}
 			}
 
-			if richTok.tok == STRING || richTok.tok == LINJECT {
+			if richTok.tok == STRING || richTok.tok == LINJECT || richTok.tok == ILLEGAL {
 				err = p.next()
 
 				if err != nil {
 					return nil, err
 				}
 
-				if p.richTok.tok == COLON {
+				if p.richTok.tok != COLON {
+					err = p.error("Must use colon : to define a string: value pair", COLON)
+
+					if !isValueStart(p.richTok.tok) {
+						if err = p.sync(err); err != nil {
+							return nil, err
+						} else if p.richTok.tok != COMMA {
+							return obj, nil
+						}
+
+						prevTok = COMMA
+						continue
+					}
+
+					// recover as if the colon were present
+					if err = p.report(err); err != nil {
+						return nil, err
+					}
+				} else {
 					err = p.next()
 
 					if err != nil {
 						return nil, err
 					}
+				}
 
-					val, _err := p.parseValue()
+				val, err := p.parseValue()
 
-					if _err != nil {
-						return nil, _err
+				if err != nil {
+					if err = p.sync(err); err != nil {
+						return nil, err
+					} else if p.richTok.tok != COMMA {
+						return obj, nil
 					}
 
-					obj[v.String(richTok.lit)] = val
-				} else {
-					return nil, fmt.Errorf("Column %d, Line %d: Must use colon : to define a string: value pair",
-						p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+					prevTok = COMMA
+					continue
 				}
+
+				obj[v.String(richTok.lit)] = val
 			} else if (richTok.tok == RBRACE && prevTok == COMMA) ||
 				(richTok.tok == COMMA && prevTok == COMMA) {
-				return nil, fmt.Errorf("Column %d, Line %d: Commas must be followed by a string: value pair",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				err = p.error("Commas must be followed by a string: value pair", STRING, LINJECT)
+
+				if err = p.report(err); err != nil {
+					return nil, err
+				} else if richTok.tok == RBRACE {
+					break
+				}
 			} else if richTok.tok == RBRACE {
 				break
 			} else if richTok.tok != COMMA {
-				return nil, fmt.Errorf("Column %d, Line %d: Left brace { not followed by comma-separated string: value pairs or right brace }",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				expected := []Token{COMMA, RBRACE}
+
+				if prevTok == LBRACE || prevTok == COMMA {
+					expected = []Token{STRING, LINJECT, RBRACE}
+				}
+
+				err = p.error("Left brace { not followed by comma-separated string: value pairs or right brace }", expected...)
+
+				if err = p.sync(err); err != nil {
+					return nil, err
+				} else if p.richTok.tok != COMMA {
+					return obj, nil
+				}
 			}
 
 			prevTok = p.richTok.tok
@@ -204,12 +459,11 @@ func (p *Parser) parseNumber() (v.Value, error) {
 		_float, _err := strconv.ParseFloat(p.richTok.lit, 64)
 
 		if _err != nil {
-			return nil, fmt.Errorf("Column %d, Line %d: Unable to parse number into int or float",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+			return nil, p.error("Unable to parse number into int or float")
 		}
 
 		// if number can be an integer, convert
-		if _float == float64(int(_float)) {
+		if p.mode&KeepFloats == 0 && _float == float64(int(_float)) {
 			return v.Integer(_float), nil
 		}
 
@@ -236,30 +490,45 @@ func (p *Parser) parseArray() (v.Value, error) {
 			richTok := p.richTok
 
 			if richTok.tok == EOF {
-				return nil, fmt.Errorf("Column %d, Line %d: No closing bracket in array",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				if err = p.report(p.error("No closing bracket in array", COMMA, RBRACK)); err != nil {
+					return nil, err
+				}
+
+				return arr, nil
 			} else if richTok.tok == RBRACK && prevTok == COMMA {
-				return nil, fmt.Errorf("Column %d, Line %d: Commas must be followed by a value",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				if err = p.report(p.error("Commas must be followed by a value", valueTokens...)); err != nil {
+					return nil, err
+				}
+
+				break
 			} else if richTok.tok == RBRACK {
 				break
-			} else if richTok.tok != COMMA && prevTok != COMMA && prevTok != LBRACK {
-				return nil, fmt.Errorf("Column %d, Line %d: Values must be separated by a comma",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
 			} else if richTok.tok == COMMA && (prevTok == LBRACK || prevTok == COMMA) {
-				return nil, fmt.Errorf("Column %d, Line %d: Must have a value between array elements",
-					p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+				if err = p.report(p.error("Must have a value between array elements", valueTokens...)); err != nil {
+					return nil, err
+				}
 			} else if richTok.tok != COMMA {
+				if prevTok != COMMA && prevTok != LBRACK && prevTok != ILLEGAL && richTok.tok != ILLEGAL {
+					// recover as if the comma were present
+					if err = p.report(p.error("Values must be separated by a comma", COMMA, RBRACK)); err != nil {
+						return nil, err
+					}
+				}
+
 				obj, err := p.parseValue()
 
 				if err != nil {
-					return nil, err
+					if err = p.sync(err); err != nil {
+						return nil, err
+					} else if p.richTok.tok != COMMA {
+						return arr, nil
+					}
+				} else {
+					arr = append(arr, obj)
 				}
-
-				arr = append(arr, obj)
 			}
 
-			prevTok = richTok.tok
+			prevTok = p.richTok.tok
 		}
 	}
 
@@ -267,14 +536,35 @@ func (p *Parser) parseArray() (v.Value, error) {
 }
 
 func (p *Parser) parseInjection() (v.Value, error) {
-	// iterate until hitting right injection
-	for p.richTok.tok != RINJECT {
-		if p.richTok.tok == EOF {
-			return nil, fmt.Errorf("Column %d, Line %d: No closing injection }} found",
-				p.scanner.srcPos.Column, p.scanner.srcPos.Line)
+	code, rinject, err := p.scanner.scanCode()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.richTok = rinject
+
+	return p.resolveInjection(code)
+}
+
+// resolveInjection returns the value injected in place of the given code
+func (p *Parser) resolveInjection(code string) (v.Value, error) {
+	if p.mode&IgnoreInjections != 0 {
+		return v.String(""), nil
+	}
+
+	if p.resolve != nil {
+		res, err := p.resolve(strings.TrimSpace(code))
+
+		if err != nil {
+			return nil, p.error(err.Error())
 		}
 
-		p.next()
+		return res, nil
+	}
+
+	if p.currInj >= len(p.injections) {
+		return nil, p.error("No value provided for injection")
 	}
 
 	res := p.injections[p.currInj]
@@ -283,3 +573,47 @@ func (p *Parser) parseInjection() (v.Value, error) {
 
 	return res, nil
 }
+
+// parseString returns the value of a string, stringifying and concatenating
+// the value of each injection inside of the string
+func (p *Parser) parseString() (v.Value, error) {
+	if p.richTok.parts == nil {
+		return v.String(p.richTok.lit), nil
+	}
+
+	var b strings.Builder
+
+	for i, part := range p.richTok.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+
+		val, err := p.resolveInjection(part)
+
+		if err != nil {
+			return nil, err
+		}
+
+		str, err := v.Stringify(val)
+
+		if err != nil {
+			return nil, p.error("Injection " + strings.TrimSpace(part) + " inside a string must be a string, number, boolean or null")
+		}
+
+		b.WriteString(str)
+	}
+
+	return v.String(b.String()), nil
+}
+
+// isValueStart returns true if a token can start a value
+func isValueStart(tok Token) bool {
+	for _, t := range valueTokens {
+		if t == tok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -6,14 +6,13 @@ package json
 // support:
 // - numbers that cause an overflow
 // - strings with null characters in them
-// - does not accept surrogate code points
-// - does not accept escape sequence \/
 // - accepts single spaces, because...why not?
 //
 // The distinction between a JSON Number and a Float/Integer type should also
 // be noted.
 
 import (
+	"bytes"
 	"testing"
 
 	v "github.com/porterdev/ego/internal/value"
@@ -344,11 +343,46 @@ var jsonTestsStringPass = []jsonTest{
 	},
 	jsonTest{
 		name: "String: double escape",
-		json: "[\"\\a\"]",
+		json: "[\"\\\\a\"]",
 		want: v.Array{
 			v.String("\\a"),
 		},
 	},
+	jsonTest{
+		name: "String: simple escapes",
+		json: "[\"\\\"\\/\\b\\f\\n\\r\\t\"]",
+		want: v.Array{
+			v.String("\"/\b\f\n\r\t"),
+		},
+	},
+	jsonTest{
+		name: "String: unicode escape",
+		json: "[\"\\u0041\\u00e9\\u12AB\"]",
+		want: v.Array{
+			v.String("A\u00e9\u12ab"),
+		},
+	},
+	jsonTest{
+		name: "String: surrogate pair",
+		json: "[\"\\uD83D\\uDE00\"]",
+		want: v.Array{
+			v.String("\U0001F600"),
+		},
+	},
+	jsonTest{
+		name: "String: escaped null",
+		json: "[\"a\\u0000b\"]",
+		want: v.Array{
+			v.String("a\x00b"),
+		},
+	},
+	jsonTest{
+		name: "String: escaped key",
+		json: "{\"a\\\"b\": \"c\"}",
+		want: v.Object{
+			v.String("a\"b"): v.String("c"),
+		},
+	},
 	jsonTest{
 		name: "String: basic in array",
 		json: "[\"hello\"]",
@@ -434,6 +468,72 @@ func TestJSONPassStructure(t *testing.T) {
 	}
 }
 
+var jsonTestsPlainPass = []jsonTest{
+	jsonTest{
+		name: "Plain: injection syntax in strings",
+		json: `{"cmd": "echo {{ .Values.tag }}", "tmpl": "{{a}}-{{b"}`,
+		want: v.Object{
+			"cmd":  v.String("echo {{ .Values.tag }}"),
+			"tmpl": v.String("{{a}}-{{b"),
+		},
+	},
+	jsonTest{
+		name: "Plain: nested braces",
+		json: `{"a":{"b":{"c":[{}]}}}`,
+		want: v.Object{
+			"a": v.Object{
+				"b": v.Object{
+					"c": v.Array{
+						v.Object{},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestJSONPassPlain(t *testing.T) {
+	for _, c := range jsonTestsPlainPass {
+		res, err := Parse([]byte(c.json))
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+
+	// a plain document has no injections
+	if _, err := Parse([]byte("{{x}}")); err == nil {
+		t.Errorf("Failed on: injection in a plain document, expected an error")
+	}
+}
+
+func TestParseExact(t *testing.T) {
+	want := v.Object{
+		"int":      v.Integer(2),
+		"float":    v.Float(2),
+		"fraction": v.Float(0.5),
+		"list": v.Array{
+			v.Float(-3),
+			v.Integer(-3),
+		},
+	}
+
+	var buf bytes.Buffer
+
+	if err := NewEncoder(&buf, EncoderOptions{}).Encode(want); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := ParseExact(buf.Bytes()); err != nil || !v.IsEqual(res, want) {
+		t.Errorf("ParseExact(%s) == %v, %v, want %v", buf.String(), res, err, want)
+	}
+
+	// integral floats are converted to integers by Parse
+	if res, _ := Parse([]byte("[2e+00, 2.0]")); !v.IsEqual(res, v.Array{v.Integer(2), v.Integer(2)}) {
+		t.Errorf("Parse() == %v, want integers", res)
+	}
+}
+
 type jsonTestFail struct {
 	name string
 	json string
@@ -514,7 +614,7 @@ var jsonTestsArrayFail = []jsonTestFail{
 	jsonTestFail{
 		name: "Array: spaces vertical tab formfeed",
 		json: "[\"a\"\f]",
-		msg: "Column 6, Line 1: Illegal token",
+		msg:  "Column 3, Line 1: Control characters in strings must be escaped",
 	},
 	jsonTestFail{
 		name: "Array: star inside",
@@ -759,6 +859,11 @@ var jsonTestsObjectFail = []jsonTestFail{
 		json: "{\"a\":\"a\" 123}",
 		msg:  "Column 13, Line 1: Left brace { not followed by comma-separated string: value pairs or right brace }",
 	},
+	jsonTestFail{
+		name: "Object: missing comma between pairs",
+		json: "{\"a\":1 \"b\":2}",
+		msg:  "Column 11, Line 1: Values must be separated by a comma",
+	},
 	jsonTestFail{
 		name: "Object: key with single quotes",
 		json: "{'key': \"value\"}",
@@ -870,6 +975,52 @@ func TestJSONFailStructure(t *testing.T) {
 	}
 }
 
+var jsonTestsStringFail = []jsonTestFail{
+	jsonTestFail{
+		name: "String: invalid escape",
+		json: "[\"\\a\"]",
+		msg:  "Column 4, Line 1: Invalid escape sequence",
+	},
+	jsonTestFail{
+		name: "String: short unicode escape",
+		json: "[\"\\u12\"]",
+		msg:  "Column 7, Line 1: Unicode escape must be followed by four hexadecimal digits",
+	},
+	jsonTestFail{
+		name: "String: lone high surrogate",
+		json: "[\"\\uD83Dx\"]",
+		msg:  "Column 8, Line 1: High surrogate must be followed by a low surrogate",
+	},
+	jsonTestFail{
+		name: "String: lone low surrogate",
+		json: "[\"\\uDE00\"]",
+		msg:  "Column 8, Line 1: Unexpected low surrogate in unicode escape",
+	},
+	jsonTestFail{
+		name: "String: high surrogate followed by non-surrogate",
+		json: "[\"\\uD83D\\u0041\"]",
+		msg:  "Column 14, Line 1: Invalid surrogate pair in unicode escape",
+	},
+	jsonTestFail{
+		name: "String: unescaped newline",
+		json: "[\"a\nb\"]",
+		msg:  "Column 0, Line 2: Control characters in strings must be escaped",
+	},
+}
+
+func TestJSONFailString(t *testing.T) {
+	for _, c := range jsonTestsStringFail {
+		src := []byte(c.json)
+		p := NewParser(src)
+
+		_, err := p.Parse()
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.json, c.msg, err)
+		}
+	}
+}
+
 type injectTest struct {
 	name string
 	json string
@@ -948,3 +1099,205 @@ func TestInjectFail(t *testing.T) {
 		}
 	}
 }
+
+type injectNamedTest struct {
+	name     string
+	json     string
+	bindings Bindings
+	want     v.Value
+	msg      string
+}
+
+var injectNamedTestsPass = []injectNamedTest{
+	injectNamedTest{
+		name: "InjectNamed: reordered keys",
+		json: "{ \"b\": {{ second }}, \"a\": {{first}} }",
+		bindings: Bindings{
+			"first":  v.Integer(1),
+			"second": v.Integer(2),
+		},
+		want: v.Object{
+			"a": v.Integer(1),
+			"b": v.Integer(2),
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: reused name",
+		json: "[{{foo.bar}}, {{foo.bar}}, {{ foo.bar }}]",
+		bindings: Bindings{
+			"foo.bar": v.String("baz"),
+		},
+		want: v.Array{
+			v.String("baz"),
+			v.String("baz"),
+			v.String("baz"),
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: key injection",
+		json: "{ {{key}}: {{val}} }",
+		bindings: Bindings{
+			"key": v.String("foo"),
+			"val": nil,
+		},
+		want: v.Object{
+			"foo": nil,
+		},
+	},
+}
+
+func TestInjectNamedPass(t *testing.T) {
+	for _, c := range injectNamedTestsPass {
+		res, err := InjectNamed(c.json, c.bindings)
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+var injectNamedTestsFail = []injectNamedTest{
+	injectNamedTest{
+		name: "InjectNamed: unknown name",
+		json: "{ \"a\": {{foo}} }",
+		bindings: Bindings{
+			"bar": v.Integer(1),
+		},
+		msg: "Column 15, Line 1: No value bound to injection foo",
+	},
+	injectNamedTest{
+		name: "InjectNamed: unused bindings",
+		json: "{ \"a\": {{foo}} }",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+			"qux": v.Integer(2),
+			"bar": v.Integer(3),
+		},
+		msg: "Bindings not used by any injection: bar, qux",
+	},
+	injectNamedTest{
+		name: "InjectNamed: key must be a string",
+		json: "{ {{foo}}: 1 }",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+		},
+		msg: "Column 10, Line 1: Key must be a string for a key injection",
+	},
+}
+
+func TestInjectNamedFail(t *testing.T) {
+	for _, c := range injectNamedTestsFail {
+		_, err := InjectNamed(c.json, c.bindings)
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.json, c.msg, err)
+		}
+	}
+}
+
+func TestInjectFunc(t *testing.T) {
+	var names []string
+
+	res, err := InjectFunc("[{{ a }}, {{b}}]", func(name string) (v.Value, error) {
+		names = append(names, name)
+
+		return v.String(name), nil
+	})
+
+	want := v.Array{v.String("a"), v.String("b")}
+
+	if err != nil || !v.IsEqual(res, want) || len(names) != 2 {
+		t.Errorf("Failed on: InjectFunc, %v, %v, %v, %v", res, want, names, err)
+	}
+}
+
+var interpolateTestsPass = []injectNamedTest{
+	injectNamedTest{
+		name: "Interpolate: suffix",
+		json: "{ \"image\": \"nginx:{{tag}}\" }",
+		bindings: Bindings{
+			"tag": v.String("1.19"),
+		},
+		want: v.Object{
+			"image": v.String("nginx:1.19"),
+		},
+	},
+	injectNamedTest{
+		name: "Interpolate: key and scalars",
+		json: "{ \"{{app}}-svc\": \"{{ port }}/{{ratio}}/{{on}}/{{none}}\" }",
+		bindings: Bindings{
+			"app":   v.String("web"),
+			"port":  v.Integer(80),
+			"ratio": v.Float(0.5),
+			"on":    v.Boolean(true),
+			"none":  nil,
+		},
+		want: v.Object{
+			"web-svc": v.String("80/0.5/true/null"),
+		},
+	},
+	injectNamedTest{
+		name: "Interpolate: escapes and adjacent injections",
+		json: "[\"\\\"{{a}}{{b}}\\u007b{c}}\"]",
+		bindings: Bindings{
+			"a": v.String("x"),
+			"b": v.String("y"),
+		},
+		want: v.Array{
+			v.String("\"xy{{c}}"),
+		},
+	},
+}
+
+func TestInterpolatePass(t *testing.T) {
+	for _, c := range interpolateTestsPass {
+		res, err := InjectNamed(c.json, c.bindings)
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+var interpolateTestsFail = []injectNamedTest{
+	injectNamedTest{
+		name: "Interpolate: object",
+		json: "{ \"a\": \"x{{obj}}\" }",
+		bindings: Bindings{
+			"obj": v.Object{},
+		},
+		msg: "Column 18, Line 1: Injection obj inside a string must be a string, number, boolean or null",
+	},
+	injectNamedTest{
+		name: "Interpolate: array",
+		json: "[\"{{ arr }}\"]",
+		bindings: Bindings{
+			"arr": v.Array{},
+		},
+		msg: "Column 13, Line 1: Injection arr inside a string must be a string, number, boolean or null",
+	},
+	injectNamedTest{
+		name:     "Interpolate: unknown name",
+		json:     "[\"{{a}}\"]",
+		bindings: Bindings{},
+		msg:      "Column 9, Line 1: No value bound to injection a",
+	},
+	injectNamedTest{
+		name: "Interpolate: unterminated injection",
+		json: "[\"{{a\"]",
+		bindings: Bindings{
+			"a": v.String("x"),
+		},
+		msg: "Column 8, Line 1: No closing injection }} found",
+	},
+}
+
+func TestInterpolateFail(t *testing.T) {
+	for _, c := range interpolateTestsFail {
+		_, err := InjectNamed(c.json, c.bindings)
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.json, c.msg, err)
+		}
+	}
+}
@@ -2,8 +2,9 @@ package json
 
 import (
 	"bytes"
-	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -17,6 +18,9 @@ type Scanner struct {
 
 	err func(pos Position, msg string)
 
+	// plain scans {{ and }} as braces and as text in strings, not as injections
+	plain bool
+
 	srcPos      Position
 	prevPos     Position
 	lastCharLen int
@@ -66,14 +70,12 @@ func (s *Scanner) next() (rune, error) {
 
 	switch {
 	case r == utf8.RuneError && size == 1: // utf-8 replacement character
-		return eof, fmt.Errorf("Column %d, Line %d: Improper UTF-8 encoding", s.srcPos.Column,
-			s.srcPos.Line)
+		return eof, s.error("Improper UTF-8 encoding")
 	case r == '\n': // new line
 		s.srcPos.Line++
 		s.srcPos.Column = 0
 	case r == '\x00': // null character
-		return eof, fmt.Errorf("Column %d, Line %d: Unexpected null character (0x00)", s.srcPos.Column,
-			s.srcPos.Line)
+		return eof, s.error("Unexpected null character (0x00)")
 	}
 
 	return r, nil
@@ -139,12 +141,12 @@ func (s *Scanner) Scan() (RichToken, error) {
 		}
 
 		return richTok, nil
-	case ch == '{' && s.peek() == '{':
+	case ch == '{' && s.peek() == '{' && !s.plain:
 		// consume next {
 		s.next()
 		lit = "{{"
 		tok = LINJECT
-	case ch == '}' && s.peek() == '}':
+	case ch == '}' && s.peek() == '}' && !s.plain:
 		// consume next }
 		s.next()
 		lit = "}}"
@@ -176,9 +178,10 @@ func (s *Scanner) Scan() (RichToken, error) {
 		case '}':
 			tok = RBRACE
 		default:
-			tok = ILLEGAL
-			return RichToken{}, fmt.Errorf("Column %d, Line %d: Illegal token",
-				s.srcPos.Column, s.srcPos.Line)
+			err := s.error("Illegal token")
+			err.Lit = lit
+
+			return RichToken{}, err
 		}
 	}
 
@@ -202,6 +205,7 @@ func (s *Scanner) Scan() (RichToken, error) {
 func (s *Scanner) scanIdentifier() (RichToken, error) {
 	offs := s.srcPos.Offset
 	startCol := s.srcPos.Column
+	startLine := s.srcPos.Line
 	ch := s.ch
 	var err error
 
@@ -214,7 +218,7 @@ func (s *Scanner) scanIdentifier() (RichToken, error) {
 	}
 
 	lit := string(s.src[offs:s.srcPos.Offset])
-	var tok Token
+	tok := ILLEGAL
 
 	switch lit {
 	case "true":
@@ -223,15 +227,20 @@ func (s *Scanner) scanIdentifier() (RichToken, error) {
 		tok = FALSE
 	case "null":
 		tok = NULL
-	default:
-		return RichToken{}, fmt.Errorf("Column %d, Line %d: Not a valid name token: must be true, false, or null. Strings must be enclosed in quotes",
-			s.srcPos.Column, s.srcPos.Line)
 	}
 
 	pos := Position{
 		Offset: offs,
 		Column: startCol,
-		Line:   s.srcPos.Line,
+		Line:   startLine,
+	}
+
+	if tok == ILLEGAL {
+		err := s.error("Not a valid name token: must be true, false, or null. Strings must be enclosed in quotes")
+		err.Lit = lit
+		err.TokenPos = pos
+
+		return RichToken{}, err
 	}
 
 	return RichToken{
@@ -241,52 +250,228 @@ func (s *Scanner) scanIdentifier() (RichToken, error) {
 	}, nil
 }
 
-// scanString scans a string and returns the literal value
-// without "
+// scanString scans a string and returns the decoded literal value
+// without ". If the string contains injections, the token also holds the
+// literal text and the code of each injection in parts.
 func (s *Scanner) scanString() (RichToken, error) {
 	// opening demarcation " already consumed
 	ch, err := s.next()
 
 	if err != nil {
-		return RichToken{}, nil
+		return RichToken{}, err
 	}
 
 	startPos := s.srcPos
 
+	var lit strings.Builder
+	var parts []string
+
 	for {
 		if ch == eof {
-			return RichToken{}, fmt.Errorf("Column %d, Line %d: String not terminated",
-				s.srcPos.Column, s.srcPos.Line)
+			return RichToken{}, s.error("String not terminated")
 		}
 
 		if ch == '"' {
 			break
 		}
 
+		if ch == '{' && s.peek() == '{' && !s.plain {
+			// consume both {, so that the scanner is at the start of the code
+			s.next()
+			s.next()
+
+			code, _, err := s.scanCode()
+
+			if err != nil {
+				return RichToken{}, err
+			}
+
+			parts = append(parts, lit.String(), code)
+			lit.Reset()
+
+			ch = s.ch
+			continue
+		}
+
+		if ch == '\\' {
+			r, err := s.scanEscape()
+
+			if err != nil {
+				s.skipString()
+				return RichToken{}, err
+			}
+
+			lit.WriteRune(r)
+		} else if ch < 0x20 {
+			err := s.error("Control characters in strings must be escaped")
+			s.skipString()
+
+			return RichToken{}, err
+		} else {
+			lit.WriteRune(ch)
+		}
+
 		ch, err = s.next()
 
 		if err != nil {
-			return RichToken{}, nil
+			return RichToken{}, err
 		}
 	}
 
-	lit := string(s.src[startPos.Offset:s.srcPos.Offset])
-
 	// consume final "
 	s.next()
 
+	if parts != nil {
+		parts = append(parts, lit.String())
+	}
+
 	return RichToken{
-		pos: startPos,
-		tok: STRING,
-		lit: lit,
+		pos:   startPos,
+		tok:   STRING,
+		lit:   lit.String(),
+		parts: parts,
 	}, nil
 }
 
+// scanEscape scans an escape sequence inside of a string, starting at the
+// backslash, and returns the decoded rune. A \uXXXX escape that encodes a
+// UTF-16 high surrogate must be followed by an escaped low surrogate.
+func (s *Scanner) scanEscape() (rune, error) {
+	ch, err := s.next()
+
+	if err != nil {
+		return eof, err
+	}
+
+	switch ch {
+	case '"', '\\', '/':
+		return ch, nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		r, err := s.scanHex()
+
+		if err != nil {
+			return eof, err
+		}
+
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+
+		// a low surrogate must be preceded by a high surrogate
+		if r >= 0xDC00 {
+			return eof, s.error("Unexpected low surrogate in unicode escape")
+		}
+
+		if s.peek() != '\\' {
+			return eof, s.error("High surrogate must be followed by a low surrogate")
+		}
+
+		s.next()
+
+		if ch, err = s.next(); err != nil {
+			return eof, err
+		} else if ch != 'u' {
+			return eof, s.error("High surrogate must be followed by a low surrogate")
+		}
+
+		r2, err := s.scanHex()
+
+		if err != nil {
+			return eof, err
+		}
+
+		res := utf16.DecodeRune(r, r2)
+
+		if res == utf8.RuneError {
+			return eof, s.error("Invalid surrogate pair in unicode escape")
+		}
+
+		return res, nil
+	}
+
+	return eof, s.error("Invalid escape sequence")
+}
+
+// scanHex scans the four hexadecimal digits of a \uXXXX escape and returns
+// the encoded code point
+func (s *Scanner) scanHex() (rune, error) {
+	var r rune
+
+	for i := 0; i < 4; i++ {
+		ch, err := s.next()
+
+		if err != nil {
+			return eof, err
+		}
+
+		switch {
+		case isDecimal(ch):
+			r = r*16 + ch - '0'
+		case 'a' <= ch && ch <= 'f':
+			r = r*16 + ch - 'a' + 10
+		case 'A' <= ch && ch <= 'F':
+			r = r*16 + ch - 'A' + 10
+		default:
+			return eof, s.error("Unicode escape must be followed by four hexadecimal digits")
+		}
+	}
+
+	return r, nil
+}
+
+// scanCode scans the raw code of an injection, starting after the opening {{,
+// and returns the code together with the closing RINJECT token. Code is not
+// tokenized, since it is Go code rather than JSON.
+func (s *Scanner) scanCode() (string, RichToken, error) {
+	offs := s.srcPos.Offset
+
+	for {
+		if s.ch == eof {
+			return "", RichToken{}, s.error("No closing injection }} found")
+		}
+
+		if s.ch == '}' && s.peek() == '}' {
+			code := string(s.src[offs:s.srcPos.Offset])
+
+			// consume first }
+			s.next()
+
+			pos := s.srcPos
+
+			// consume second }
+			if _, err := s.next(); err != nil {
+				return "", RichToken{}, err
+			}
+
+			return code, RichToken{
+				pos: pos,
+				tok: RINJECT,
+				lit: "}}",
+			}, nil
+		}
+
+		if _, err := s.next(); err != nil {
+			return "", RichToken{}, err
+		}
+	}
+}
+
 // scanNumber scans a number and returns the token NUMBER
 // and the accompanying value as a literal string
 func (s *Scanner) scanNumber() (RichToken, error) {
 	offs := s.srcPos.Offset
 	startCol := s.srcPos.Column
+	startLine := s.srcPos.Line
 	tok := NUMBER
 	ch := s.ch
 	var err error
@@ -298,8 +483,7 @@ func (s *Scanner) scanNumber() (RichToken, error) {
 
 	if ch == '0' {
 		if isDecimal(s.peek()) {
-			return RichToken{}, fmt.Errorf("Column %d, Line %d: 0 cannot be followed by digit without . or exponent",
-				s.srcPos.Column, s.srcPos.Line)
+			return RichToken{}, s.error("0 cannot be followed by digit without . or exponent")
 		}
 
 		ch, err = s.next()
@@ -324,8 +508,7 @@ func (s *Scanner) scanNumber() (RichToken, error) {
 			return RichToken{}, err
 		} else if !isDecimal(ch) {
 			// must have at least one decimal
-			return RichToken{}, fmt.Errorf("Column %d, Line %d: . must be followed by at least one digit",
-				s.srcPos.Column, s.srcPos.Line)
+			return RichToken{}, s.error(". must be followed by at least one digit")
 		}
 
 		for isDecimal(ch) {
@@ -356,8 +539,7 @@ func (s *Scanner) scanNumber() (RichToken, error) {
 
 		// must have at least one decimal
 		if !isDecimal(ch) {
-			return RichToken{}, fmt.Errorf("Column %d, Line %d: e or E must be followed by at least one digit",
-				s.srcPos.Column, s.srcPos.Line)
+			return RichToken{}, s.error("e or E must be followed by at least one digit")
 		}
 
 		// exponent followed by arbitrary number of decimals
@@ -374,13 +556,50 @@ func (s *Scanner) scanNumber() (RichToken, error) {
 		pos: Position{
 			Offset: offs,
 			Column: startCol,
-			Line:   s.srcPos.Line,
+			Line:   startLine,
 		},
 		tok: tok,
 		lit: string(s.src[offs:s.srcPos.Offset]),
 	}, nil
 }
 
+// skipIllegal advances the scanner past the remainder of a token that could
+// not be scanned, stopping at whitespace or the start of another token
+func (s *Scanner) skipIllegal() {
+	for {
+		switch s.ch {
+		case eof, ' ', '\t', '\n', '\r', ',', ':', '[', ']', '{', '}', '"':
+			return
+		}
+
+		s.next()
+	}
+}
+
+// skipString advances the scanner past the closing " of a string that could
+// not be scanned
+func (s *Scanner) skipString() {
+	for s.ch != eof && s.ch != '"' {
+		if s.ch == '\\' {
+			s.next()
+		}
+
+		s.next()
+	}
+
+	s.next()
+}
+
+// error creates a syntax error at the current position of the scanner
+func (s *Scanner) error(msg string) *SyntaxError {
+	return &SyntaxError{
+		Pos:      s.srcPos,
+		Token:    ILLEGAL,
+		TokenPos: s.srcPos,
+		Msg:      msg,
+	}
+}
+
 // isLetter returns true if the given rune is a letter
 func isLetter(ch rune) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= 0x80 && unicode.IsLetter(ch)
@@ -108,6 +108,17 @@ var singleTestLiterals = [...]singleTest{
 		},
 		"\"Arbitrary characters !@#$%^&*()\"",
 	},
+	{
+		want{
+			richTok: RichToken{
+				pos: Position{Offset: 1, Line: 1, Column: 2},
+				tok: STRING,
+				lit: "tab\tquote\"unicode\u00e9",
+			},
+			class: literal,
+		},
+		"\"tab\\tquote\\\"unicode\\u00e9\"",
+	},
 	{
 		want{
 			richTok: RichToken{
@@ -448,6 +459,27 @@ var multiTests = [...]multiTest{
 		},
 		"{ \n\"foo\": [0, 1]\n }",
 	},
+	{
+		[]want{
+			want{
+				richTok: RichToken{
+					pos: Position{Offset: 0, Line: 1, Column: 1},
+					tok: TRUE,
+					lit: "true",
+				},
+				class: literal,
+			},
+			want{
+				richTok: RichToken{
+					pos: Position{Offset: 5, Line: 2, Column: 1},
+					tok: NUMBER,
+					lit: "12",
+				},
+				class: literal,
+			},
+		},
+		"true\n12\n",
+	},
 }
 
 func TestScanMulti(t *testing.T) {
@@ -4,6 +4,8 @@
 
 package json
 
+import "strconv"
+
 // Token is the set of lexical tokens of the JSON text syntax.
 type Token int
 
@@ -13,6 +15,10 @@ type RichToken struct {
 	pos Position // the position of the token
 	tok Token    // the token
 	lit string   // the literal value corresponding to a token
+
+	// for a string containing injections, the literal text before, between
+	// and after injections at even indices, and injection code at odd indices
+	parts []string
 }
 
 // create token enumeration
@@ -62,6 +68,15 @@ var Tokens = [...]string{
 	RINJECT: "}}",
 }
 
+// String returns the string value of a token
+func (tok Token) String() string {
+	if 0 <= tok && int(tok) < len(Tokens) && Tokens[tok] != "" {
+		return Tokens[tok]
+	}
+
+	return "token(" + strconv.Itoa(int(tok)) + ")"
+}
+
 // IsLiteral determines if a given token tok is a literal; true if yes,
 // false otherwise
 //
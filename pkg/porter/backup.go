@@ -0,0 +1,242 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultBackupRetention is the retention of the backups of a LocalStore
+// created with NewLocalStore. Every backup is kept unless a retention is set,
+// or the backups are pruned with PruneBackups.
+var DefaultBackupRetention = BackupRetention{}
+
+// backupID matches the ID of a backup, the time at which it was written
+var backupID = regexp.MustCompile(`^\d+$`)
+
+// Backup describes a backup of the state of a configuration. ID identifies the
+// backup in GetBackup and RestoreBackup.
+type Backup struct {
+	ID   string
+	Path string
+	Time time.Time  // time at which the backup was written
+	Meta *StateMeta // metadata of the backed up state, nil if Err is set
+	Err  error      // error that prevented the backup from being decoded
+}
+
+// BackupRetention limits the backups of the state of a configuration that are
+// kept. Backups that are not among the KeepLast most recent backups, or that are
+// older than MaxAge, are removed. A zero value does not limit backups.
+type BackupRetention struct {
+	KeepLast int
+	MaxAge   time.Duration
+}
+
+// backupFile returns the path of the backup with an ID
+func (s *LocalStore) backupFile(id string) string {
+	return filepath.Join(s.BackupDir, "backup_"+s.ID+"_"+id+".json")
+}
+
+// GetAllBackups returns the backups of the state in BackupDir, sorted from most
+// recent to least recent. Backups that cannot be decoded are listed with their
+// error.
+func (s *LocalStore) GetAllBackups() ([]*Backup, error) {
+	backups, err := s.listBackups()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		dat, err := ioutil.ReadFile(backup.Path)
+
+		if err == nil {
+			_, backup.Meta, err = decodeState(dat)
+		}
+
+		backup.Err = err
+	}
+
+	return backups, nil
+}
+
+// listBackups returns the backups of the state in BackupDir from their file
+// names, sorted from most recent to least recent, without reading them
+func (s *LocalStore) listBackups() ([]*Backup, error) {
+	backups := []*Backup{}
+	pattern := regexp.MustCompile("^backup_" + regexp.QuoteMeta(s.ID) + `_(\d+)\.json$`)
+
+	fileinfos, err := ioutil.ReadDir(s.BackupDir)
+
+	if err != nil {
+		return nil, &StoreError{ID: s.ID, Op: "list backups", Path: s.BackupDir, Err: err}
+	}
+
+	for _, fi := range fileinfos {
+		match := pattern.FindStringSubmatch(fi.Name())
+
+		if match == nil || fi.IsDir() {
+			continue
+		}
+
+		backups = append(backups, &Backup{
+			ID:   match[1],
+			Path: filepath.Join(s.BackupDir, fi.Name()),
+			Time: backupTime(match[1]),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+
+		return backups[i].ID > backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// backupTime returns the time at which a backup was written from its ID. Backups
+// written before IDs were in nanoseconds have IDs in seconds.
+func backupTime(id string) time.Time {
+	n, _ := strconv.ParseInt(id, 10, 64)
+
+	if len(id) <= 10 {
+		return time.Unix(n, 0)
+	}
+
+	return time.Unix(0, n)
+}
+
+// GetBackup returns the state stored in the backup with an ID.
+func (s *LocalStore) GetBackup(id string) (Object, error) {
+	if !backupID.MatchString(id) {
+		return nil, &StoreError{ID: s.ID, Op: "read backup", Err: fmt.Errorf("Invalid backup ID %q", id)}
+	}
+
+	filename := s.backupFile(id)
+
+	dat, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, &StoreError{ID: s.ID, Op: "read backup", Path: filename, Err: err}
+	}
+
+	res, _, err := decodeState(dat)
+
+	if err != nil {
+		return nil, &StoreError{ID: s.ID, Op: "decode backup", Path: filename, Err: err}
+	}
+
+	return res, nil
+}
+
+// WriteBackup takes in a state file and writes a copy of it as a backup, using
+// the ID stored in the LocalStore object. Backups are then removed according to
+// the Retention of the store.
+func (s *LocalStore) WriteBackup(filename string) error {
+	dat, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return &StoreError{ID: s.ID, Op: "read state", Path: filename, Err: err}
+	}
+
+	dir := filepath.Dir(filename)
+
+	// if s.StateDir is not same directory as filename, throw an error
+	if rel, err := filepath.Rel(dir, s.StateDir); err != nil || rel != "." {
+		return &StoreError{
+			ID:   s.ID,
+			Op:   "write backup",
+			Path: filename,
+			Err:  errors.New("LocalStore Path does not match filename directory"),
+		}
+	}
+
+	backup := s.backupFile(strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	if err := writeFileAtomic(backup, dat); err != nil {
+		return &StoreError{ID: s.ID, Op: "write backup", Path: backup, Err: err}
+	}
+
+	// the backup is written, so failing to remove old backups is not an error
+	if _, err := s.PruneBackups(s.Retention); err != nil {
+		s.Logger.Log(WARNING, s.ID, "could not remove old backups:", err)
+	}
+
+	return nil
+}
+
+// PruneBackups removes the backups that are not kept by a retention, and returns
+// the removed backups. Backups are selected by the time in their file name, and
+// are not decoded.
+func (s *LocalStore) PruneBackups(r BackupRetention) ([]*Backup, error) {
+	if r.KeepLast <= 0 && r.MaxAge <= 0 {
+		return nil, nil
+	}
+
+	backups, err := s.listBackups()
+
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []*Backup{}
+	now := time.Now()
+
+	for i, backup := range backups {
+		if (r.KeepLast > 0 && i >= r.KeepLast) || (r.MaxAge > 0 && now.Sub(backup.Time) > r.MaxAge) {
+			if err := os.Remove(backup.Path); err != nil {
+				return removed, &StoreError{ID: s.ID, Op: "remove backup", Path: backup.Path, Err: err}
+			}
+
+			removed = append(removed, backup)
+		}
+	}
+
+	return removed, nil
+}
+
+// RestoreBackup replaces the state with the state stored in the backup with an
+// ID. The replaced state is backed up first, so that a restore can be undone.
+// The restored state continues the serial and lineage of the replaced state.
+// A state that cannot be decoded because it is corrupt is replaced as well.
+func (s *LocalStore) RestoreBackup(id string) error {
+	state, err := s.GetBackup(id)
+
+	if err != nil {
+		return err
+	}
+
+	_, current, err := s.readState()
+
+	if errors.Is(err, ErrCorruptState) {
+		filename := s.stateFile()
+
+		if err := s.WriteBackup(filename); err != nil {
+			return err
+		}
+
+		s.Logger.Log(WARNING, s.ID, "replacing corrupt state", filename, "with backup", id)
+
+		// the corrupt state is replaced atomically, so that it is kept if the
+		// restore fails
+		s.checkpointed = false
+
+		return s.replaceState(state, &StateMeta{}, false)
+	} else if err != nil {
+		return err
+	}
+
+	s.seen = current
+	s.checkpointed = false
+
+	return s.writeState(state, true)
+}
@@ -0,0 +1,252 @@
+package porter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// writeTestBackup writes a backup of a state at a time
+func writeTestBackup(t *testing.T, store *LocalStore, id string, state Object) {
+	meta := &StateMeta{Version: StateFormatVersion, Serial: 1, Lineage: "test"}
+	meta.Checksum, _ = StateHash(state)
+
+	dat, err := encodeState(state, meta)
+
+	if err == nil {
+		err = ioutil.WriteFile(store.backupFile(id), dat, 0644)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAllBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-backup")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	other, _ := NewLocalStore("1234", NewLogger(0), dir, dir)
+
+	now := time.Now()
+	state := v.Object{v.String("hello"): v.String("there")}
+
+	// a backup with an ID in seconds, as written by older versions
+	writeTestBackup(t, store, strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), state)
+	writeTestBackup(t, store, strconv.FormatInt(now.UnixNano(), 10), state)
+	writeTestBackup(t, store, strconv.FormatInt(now.Add(-time.Minute).UnixNano(), 10), state)
+	writeTestBackup(t, other, strconv.FormatInt(now.UnixNano(), 10), state)
+	ioutil.WriteFile(store.backupFile("1"), []byte("{"), 0644)
+
+	backups, err := store.GetAllBackups()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backups) != 4 {
+		t.Fatalf("Expected 4 backups, got %d", len(backups))
+	}
+
+	for i := 1; i < len(backups); i++ {
+		if backups[i].Time.After(backups[i-1].Time) {
+			t.Errorf("Backup %d (%s) is more recent than backup %d (%s)", i, backups[i].Time, i-1, backups[i-1].Time)
+		}
+	}
+
+	last := backups[len(backups)-1]
+
+	if !errors.Is(last.Err, ErrCorruptState) || last.Meta != nil {
+		t.Errorf("Failed on corrupt backup, %v, %v", last.Err, last.Meta)
+	}
+
+	if backups[0].Meta == nil || backups[0].Meta.Lineage != "test" || backups[0].Path != store.backupFile(backups[0].ID) {
+		t.Errorf("Failed on backup metadata, %+v", backups[0])
+	}
+
+	if res, err := store.GetBackup(backups[1].ID); err != nil || !v.IsEqual(res, state) {
+		t.Errorf("GetBackup() == %v, %v, want %v", res, err, state)
+	}
+
+	if _, err := store.GetBackup("../state_12345"); err == nil {
+		t.Errorf("GetBackup() accepted an invalid ID")
+	}
+}
+
+type pruneTest struct {
+	name      string
+	retention BackupRetention
+	want      int
+}
+
+var pruneTests = []pruneTest{
+	pruneTest{
+		name:      "No retention",
+		retention: BackupRetention{},
+		want:      6,
+	},
+	pruneTest{
+		name:      "Keep last",
+		retention: BackupRetention{KeepLast: 2},
+		want:      2,
+	},
+	pruneTest{
+		name:      "Max age",
+		retention: BackupRetention{MaxAge: 150 * time.Minute},
+		want:      3,
+	},
+	pruneTest{
+		name:      "Keep last and max age",
+		retention: BackupRetention{KeepLast: 4, MaxAge: 150 * time.Minute},
+		want:      3,
+	},
+}
+
+func TestPruneBackups(t *testing.T) {
+	for _, c := range pruneTests {
+		dir, err := ioutil.TempDir("", "porter-backup")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.RemoveAll(dir)
+
+		store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+		now := time.Now()
+
+		// backups from 0 to 4 hours old
+		for i := 0; i < 5; i++ {
+			ts := now.Add(-time.Duration(i) * time.Hour).UnixNano()
+			writeTestBackup(t, store, strconv.FormatInt(ts, 10), v.Object{v.String("i"): v.Integer(i)})
+		}
+
+		// backups that cannot be decoded are pruned by their file name
+		ioutil.WriteFile(store.backupFile(strconv.FormatInt(now.Add(-5*time.Hour).UnixNano(), 10)), []byte("{"), 0644)
+
+		removed, err := store.PruneBackups(c.retention)
+		backups, _ := store.GetAllBackups()
+
+		if err != nil || len(backups) != c.want || len(removed)+len(backups) != 6 {
+			t.Errorf("Failed on: %s, kept %d backups, want %d, %v", c.name, len(backups), c.want, err)
+			continue
+		}
+
+		// the most recent backups are kept
+		for i, b := range backups {
+			if i == 5 {
+				break
+			}
+
+			if res, _ := store.GetBackup(b.ID); !v.IsEqual(res, v.Object{v.String("i"): v.Integer(i)}) {
+				t.Errorf("Failed on: %s, backup %d is %v", c.name, i, res)
+			}
+		}
+	}
+}
+
+func TestBackupRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-backup")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+
+	// every backup is kept by default
+	for i := 0; i < 4; i++ {
+		store.WriteState(v.Object{v.String("i"): v.Integer(i)})
+	}
+
+	backups, _ := store.GetAllBackups()
+
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 backups by default, got %d", len(backups))
+	}
+
+	store.Retention = BackupRetention{KeepLast: 3}
+
+	for i := 4; i < 6; i++ {
+		store.WriteState(v.Object{v.String("i"): v.Integer(i)})
+	}
+
+	backups, _ = store.GetAllBackups()
+
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 backups, got %d", len(backups))
+	}
+
+	if res, _ := store.GetBackup(backups[0].ID); !v.IsEqual(res, v.Object{v.String("i"): v.Integer(4)}) {
+		t.Errorf("Most recent backup is %v", res)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-backup")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val1 := v.Object{v.String("hello"): v.String("there")}
+	val2 := v.Object{v.String("general"): v.String("kenobi")}
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	store.WriteState(val1)
+	store.WriteState(val2)
+
+	backups, _ := store.GetAllBackups()
+
+	if err := store.RestoreBackup(backups[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, val1) {
+		t.Errorf("GetState() == %v, %v after restore, want %v", state, err, val1)
+	}
+
+	// the replaced state is backed up, and the serial continues
+	backups, _ = store.GetAllBackups()
+
+	if res, _ := store.GetBackup(backups[0].ID); len(backups) != 2 || !v.IsEqual(res, val2) {
+		t.Errorf("Failed on restore backup, %d backups, %v", len(backups), res)
+	}
+
+	if meta, _ := store.GetStateMeta(); meta.Serial != 3 {
+		t.Errorf("Serial is %d after restore, want 3", meta.Serial)
+	}
+
+	// a corrupt state is replaced
+	ioutil.WriteFile(filename, []byte("{"), 0644)
+
+	if err := store.RestoreBackup(backups[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, val2) {
+		t.Errorf("GetState() == %v, %v after restoring a corrupt state, want %v", state, err, val2)
+	}
+
+	if err := store.RestoreBackup("0"); err == nil {
+		t.Errorf("RestoreBackup() restored a backup that does not exist")
+	}
+}
@@ -1,6 +1,10 @@
 package porter
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/porterdev/ego/internal/plan"
 	v "github.com/porterdev/ego/internal/value"
 )
@@ -31,19 +35,55 @@ type Config interface {
 // The DefaultConfig struct logs at two levels: error and info. If LogLevel is 0, only
 // errors are logged. If LogLevel is 1, warning and error logs are written. If LogLevel
 // is 2, all logs are written.
+//
+// PlanOptions configures how the default Plan() diffs configurations, e.g. to
+// match the containers of a deployment by name rather than by index, or to
+// replace a resource when one of its immutable (ForceNew) fields changes.
+// ExecOptions configures how Apply runs the operations of a plan: operations run
+// one at a time unless ExecOptions.Parallelism is greater than 1, in which case
+// Run() and Validate() must be safe to call concurrently. If ExecOptions.Timeout
+// is set, the context of each operation run by RunContext() is cancelled after
+// the timeout, see ContextConfig. OnFailure sets what Apply does with the
+// operations that already ran when an operation fails.
+//
+// Apply locks the Store while it runs, and waits at most LockTimeout for
+// another process to release the lock.
 type DefaultConfig struct {
 	ID string
 
 	Logger *Logger
 	Store  *LocalStore
+
+	PlanOptions plan.Options
+	ExecOptions plan.ExecOptions
+	OnFailure   FailurePolicy
+	LockTimeout time.Duration
 }
 
-// CreateDefaultConfig creates a new configuration based on an ID and a logLevel.
-func CreateDefaultConfig(id string, stateDir string, backupDir string, logLevel int) *DefaultConfig {
+// FailurePolicy is what Apply does when an operation of a plan fails
+type FailurePolicy int
+
+// The failure policies. SaveOnFailure stops and saves the partially applied
+// state, and is the default. RollbackOnFailure runs the inverse of each
+// operation that ran, in the reverse order, e.g. a DELETE for a CREATE. If a
+// rollback fails, the state of the operations that were not rolled back is
+// saved.
+const (
+	SaveOnFailure FailurePolicy = iota
+	RollbackOnFailure
+)
+
+// NewDefaultConfig creates a new configuration based on an ID and a logLevel,
+// with a LocalStore in stateDir and backupDir. Returns a *StoreError if the
+// store cannot be initialized.
+func NewDefaultConfig(id string, stateDir string, backupDir string, logLevel int) (*DefaultConfig, error) {
 	logger := NewLogger(logLevel)
 
 	store, err := NewLocalStore(id, logger, stateDir, backupDir)
-	logger.Check(err, id, "could not initialize store")
+
+	if err != nil {
+		return nil, &StoreError{ID: id, Op: "initialize store", Path: stateDir, Err: err}
+	}
 
 	conf := DefaultConfig{
 		ID:     id,
@@ -51,49 +91,353 @@ func CreateDefaultConfig(id string, stateDir string, backupDir string, logLevel
 		Store:  store,
 	}
 
-	return &conf
+	return &conf, nil
+}
+
+// CreateDefaultConfig creates a new configuration like NewDefaultConfig, and
+// panics if the store cannot be initialized.
+func CreateDefaultConfig(id string, stateDir string, backupDir string, logLevel int) *DefaultConfig {
+	conf, err := NewDefaultConfig(id, stateDir, backupDir, logLevel)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return conf
 }
 
 // Apply runs an application loop for a given Config. This should never be overwritten.
 // Returns the new configuration.
 func (c DefaultConfig) Apply(input Object) (Object, error) {
-	old, err := c.Data(input)
+	return Apply(c, input)
+}
 
-	c.Logger.Check(err, c.ID, "data retrieval failed")
-	c.Logger.Log(INFO, c.ID, "successfully retrieved data for configuration")
+// Apply runs the application loop for a Config: it retrieves the current data,
+// generates the new configuration, plans and runs the operations between them,
+// and saves the new configuration. Since Apply calls the methods of the Config
+// interface, it calls the methods overwritten by a type that embeds DefaultConfig.
+// Returns the new configuration.
+//
+// Errors are returned as a *PlanError if no plan could be made, a
+// *plan.ExecError that wraps a *RunError for each failed operation, or a
+// *StoreError if the new configuration could not be saved. A panic in a method
+// of the Config, including a runtime error, is returned as a *plan.PanicError
+// with the stack trace, within a *RunError if it happened while an operation
+// ran.
+func Apply(c Config, input Object) (Object, error) {
+	return ApplyContext(context.Background(), AdaptConfig(c), input)
+}
 
-	new, err := c.Generate(input)
+// ApplyContext runs the application loop for a ContextConfig, like Apply. ctx is
+// passed to the methods of the ContextConfig, and each operation has its own
+// context if ExecOptions.Timeout is set. When ctx is done, the operations that
+// are running finish, and the partially applied state is saved: the error is a
+// *plan.ExecError that wraps the error of ctx.
+func ApplyContext(ctx context.Context, c ContextConfig, input Object) (res Object, err error) {
+	defer catch(&err)
 
-	c.Logger.Check(err, c.ID, "config generation failed")
-	c.Logger.Log(INFO, c.ID, "successfully generated configuration")
+	unlock, err := lockStore(c, ModeApply)
 
-	q, err := c.Plan(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	old, new, q, err := generatePlan(ctx, c, input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return execute(ctx, c, old, new, q)
+}
+
+// execute runs and validates each operation of the plan from old to new for a
+// Config, and saves the new configuration. Operations run in dependency order,
+// see plan.Execute. After each operation that runs, the partially applied state
+// is checkpointed through the Store, so that a run that does not complete does
+// not forget the operations that already ran. If an operation fails, the
+// operations that already ran are rolled back or saved, depending on the
+// FailurePolicy. If ctx is done, they are saved. Returns the new configuration.
+func execute(ctx context.Context, c ContextConfig, old Object, new Object, q *plan.OpQueue) (Object, error) {
+	d := defaultsOf(c)
+	p := newProgress(d, old, new, q)
 
-	c.Logger.Check(err, c.ID, "plan failed")
-	c.Logger.Log(INFO, c.ID, "successfully generated plan")
+	err := plan.Execute(ctx, q, func(ctx context.Context, op *plan.Operation) (err error) {
+		// a panic outside of the methods of the Config, e.g. while checkpointing
+		defer func() {
+			if panicErr, ok := err.(*plan.PanicError); ok {
+				err = &RunError{ID: d.ID, Op: op, Stage: "run", Err: panicErr}
+			}
+		}()
 
-	for !q.IsEmpty() {
-		op := q.Dequeue()
+		defer catch(&err)
 
-		err := c.Run(op)
+		if err := call(ctx, c.RunContext, op); err != nil {
+			d.Logger.Log(ERROR, d.ID, "run", op.ToString(), "failed", err.Error())
+			return &RunError{ID: d.ID, Op: op, Stage: "run", Err: err}
+		}
 
-		c.Logger.Check(err, c.ID, "run", op.ToString(), "failed")
-		c.Logger.Log(INFO, c.ID, "successfully ran:", op.ToString())
+		p.done(op)
 
-		err = c.Validate(op)
+		d.Logger.Log(INFO, d.ID, "successfully ran:", op.ToString())
 
-		c.Logger.Check(err, c.ID, "validate", op.ToString(), "failed")
-		c.Logger.Log(INFO, c.ID, "successfully validated:", op.ToString())
+		if err := call(ctx, c.ValidateContext, op); err != nil {
+			d.Logger.Log(ERROR, d.ID, "validate", op.ToString(), "failed", err.Error())
+			return &RunError{ID: d.ID, Op: op, Stage: "validate", Err: err}
+		}
+
+		d.Logger.Log(INFO, d.ID, "successfully validated:", op.ToString())
+
+		return nil
+	}, d.ExecOptions)
+
+	if execErr, ok := err.(*plan.ExecError); ok {
+		recoverFailure(ctx, c, p, execErr)
+	} else if err != nil {
+		// no operation ran
+		err = &PlanError{ID: d.ID, Stage: "order", Err: err}
 	}
 
-	err = c.Save(new)
+	if err != nil {
+		d.Logger.Log(ERROR, d.ID, "apply failed", err.Error())
+		return nil, err
+	}
 
-	c.Logger.Check(err, c.ID, "save failed")
-	c.Logger.Log(INFO, c.ID, "successfully saved")
+	if err := c.SaveContext(detach(ctx), new); err != nil {
+		d.Logger.Log(ERROR, d.ID, "save failed", err.Error())
+		return nil, storeError(d.ID, "save state", "", err)
+	}
+
+	d.Logger.Log(INFO, d.ID, "successfully saved")
 
 	return new, nil
 }
 
+// lockStore locks the Store of a Config for an operation, if it has a Store,
+// and returns the function that unlocks it
+func lockStore(c interface{}, operation string) (func(), error) {
+	d := defaultsOf(c)
+
+	if d.Store == nil {
+		return func() {}, nil
+	}
+
+	if err := d.Store.Lock(operation, d.LockTimeout); err != nil {
+		d.Logger.Log(ERROR, d.ID, "lock failed", err.Error())
+		return nil, err
+	}
+
+	return func() {
+		if err := d.Store.Unlock(); err != nil {
+			d.Logger.Log(ERROR, d.ID, "unlock failed", err.Error())
+		}
+	}, nil
+}
+
+// call calls a method of a Config for an operation, and returns a panic as an
+// error
+func call(ctx context.Context, method func(ctx context.Context, op *plan.Operation) error, op *plan.Operation) (err error) {
+	defer catch(&err)
+
+	return method(ctx, op)
+}
+
+// progress tracks the operations of a plan that have been applied, and
+// checkpoints the partially applied state through the Store
+type progress struct {
+	d DefaultConfig
+
+	old Object
+	new Object
+	ops []*plan.Operation
+
+	mu      sync.Mutex
+	ran     *plan.OpStack // the operations that ran, in the order that they ran
+	applied map[*plan.Operation]bool
+}
+
+// newProgress returns the progress of a plan from old to new that has not run
+func newProgress(d DefaultConfig, old Object, new Object, q *plan.OpQueue) *progress {
+	return &progress{
+		d:       d,
+		old:     old,
+		new:     new,
+		ops:     q.Ops(),
+		ran:     plan.NewOpStack(),
+		applied: make(map[*plan.Operation]bool),
+	}
+}
+
+// done records that an operation ran, and checkpoints the state
+func (p *progress) done(op *plan.Operation) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ran.Push(op)
+	p.applied[op] = true
+
+	p.checkpoint()
+}
+
+// undo records that the last operation that ran was rolled back, and
+// checkpoints the state
+func (p *progress) undo() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.applied, p.ran.Pop())
+
+	p.checkpoint()
+}
+
+// state returns the state of the configuration with the operations that ran
+func (p *progress) state() Object {
+	return plan.AppliedState(p.old, p.new, p.ops, func(op *plan.Operation) bool {
+		return p.applied[op]
+	})
+}
+
+// checkpoint writes the state through the Store. A checkpoint that fails is
+// logged, since the operations that ran cannot be undone.
+func (p *progress) checkpoint() {
+	if p.d.Store == nil {
+		return
+	}
+
+	if err := p.d.Store.WriteCheckpoint(p.state()); err != nil {
+		p.d.Logger.Log(ERROR, p.d.ID, "checkpoint failed", err.Error())
+	}
+}
+
+// recoverFailure handles a failed plan according to the FailurePolicy: the
+// operations that ran are either rolled back, by running their inverse in the
+// reverse order, or kept. They are always kept if no operation failed, i.e. if
+// the plan was interrupted. In both cases, the state of the operations that
+// remain applied is saved, so that the next plan starts from the actual state.
+// Rolling back and saving are not interrupted when ctx is done.
+func recoverFailure(ctx context.Context, c ContextConfig, p *progress, execErr *plan.ExecError) {
+	d := defaultsOf(c)
+	ctx = detach(ctx)
+
+	if p.ran.IsEmpty() {
+		return
+	}
+
+	if d.OnFailure == RollbackOnFailure && len(execErr.Failed) > 0 {
+		for !p.ran.IsEmpty() {
+			inv := p.ran.Peek().Inverse()
+
+			if inv != nil {
+				if err := rollback(ctx, c, inv, d.ExecOptions.Timeout); err != nil {
+					d.Logger.Log(ERROR, d.ID, "rollback", inv.ToString(), "failed", err.Error())
+					break
+				}
+
+				d.Logger.Log(INFO, d.ID, "successfully rolled back:", inv.ToString())
+			}
+
+			p.undo()
+		}
+	}
+
+	if err := c.SaveContext(ctx, p.state()); err != nil {
+		d.Logger.Log(ERROR, d.ID, "saving partially applied state failed", err.Error())
+		return
+	}
+
+	if len(p.applied) > 0 {
+		d.Logger.Log(WARNING, d.ID, "saved partially applied state after", len(p.applied), "operations")
+	}
+}
+
+// rollback runs the inverse of an operation, with a context that expires after
+// timeout if it is greater than 0
+func rollback(ctx context.Context, c ContextConfig, inv *plan.Operation, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return call(ctx, c.RunContext, inv)
+}
+
+// PlanOnly retrieves the current data and generates the new configuration for
+// a Config, and returns the operations that Apply would run, without running
+// them or saving the new configuration.
+func (c DefaultConfig) PlanOnly(input Object) (*plan.OpQueue, error) {
+	return PlanOnly(c, input)
+}
+
+// PlanOnly retrieves the current data and generates the new configuration for
+// a Config, and returns the operations that Apply would run, without running
+// them or saving the new configuration.
+func PlanOnly(c Config, input Object) (q *plan.OpQueue, err error) {
+	defer catch(&err)
+
+	_, _, q, err = generatePlan(context.Background(), AdaptConfig(c), input)
+
+	return q, err
+}
+
+// generatePlan retrieves the current data, generates the new configuration and
+// plans the operations between them. Returns the current data, the new
+// configuration and the plan, or a *PlanError.
+func generatePlan(ctx context.Context, c ContextConfig, input Object) (Object, Object, *plan.OpQueue, error) {
+	d := defaultsOf(c)
+
+	old, err := c.DataContext(ctx, input)
+
+	if err != nil {
+		d.Logger.Log(ERROR, d.ID, "data retrieval failed", err.Error())
+		return nil, nil, nil, &PlanError{ID: d.ID, Stage: "data", Err: err}
+	}
+
+	d.Logger.Log(INFO, d.ID, "successfully retrieved data for configuration")
+
+	new, err := c.GenerateContext(ctx, input)
+
+	if err != nil {
+		d.Logger.Log(ERROR, d.ID, "config generation failed", err.Error())
+		return nil, nil, nil, &PlanError{ID: d.ID, Stage: "generate", Err: err}
+	}
+
+	d.Logger.Log(INFO, d.ID, "successfully generated configuration")
+
+	q, err := c.Plan(old, new)
+
+	if err != nil {
+		d.Logger.Log(ERROR, d.ID, "plan failed", err.Error())
+		return nil, nil, nil, &PlanError{ID: d.ID, Stage: "plan", Err: err}
+	}
+
+	d.Logger.Log(INFO, d.ID, "successfully generated plan")
+
+	return old, new, q, nil
+}
+
+// defaults returns the DefaultConfig, so that it can be retrieved from a type
+// that embeds it
+func (c DefaultConfig) defaults() DefaultConfig {
+	return c
+}
+
+// defaultsOf returns the DefaultConfig embedded in a Config or a ContextConfig.
+// If the Config does not embed a DefaultConfig, a DefaultConfig that only logs
+// errors is returned.
+func defaultsOf(c interface{}) DefaultConfig {
+	if d, ok := c.(interface{ defaults() DefaultConfig }); ok && d.defaults().Logger != nil {
+		return d.defaults()
+	}
+
+	return DefaultConfig{
+		Logger: NewLogger(ERROR),
+	}
+}
+
 // Data is the default implementation of Config.Data(), and can optionally be overwritten.
 // It simply returns the input values as the retrieved data for this configuration.
 func (c DefaultConfig) Data(input Object) (Object, error) {
@@ -110,7 +454,7 @@ func (c DefaultConfig) Generate(input Object) (Object, error) {
 // unless you know what you are doing. It generates an execution plan by comparing the
 // passed Value against a previously stored Value.
 func (c DefaultConfig) Plan(old Object, new Object) (*plan.OpQueue, error) {
-	return plan.CreateOpQueue(old, new), nil
+	return plan.CreateOpQueueOptions(old, new, c.PlanOptions), nil
 }
 
 // Run is the default implementation of Config.Run(), and should be overwritten. This
@@ -1,13 +1,27 @@
 package porter
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 
+	"github.com/porterdev/ego/internal/plan"
 	v "github.com/porterdev/ego/internal/value"
 )
 
 func TestSimpleConfigJustInput(t *testing.T) {
-	conf := CreateDefaultConfig("12345", "./", "./", 2)
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := CreateDefaultConfig("12345", dir, dir, 2)
 
 	input := v.Object{
 		v.String("hello"): v.String("there"),
@@ -15,3 +29,311 @@ func TestSimpleConfigJustInput(t *testing.T) {
 
 	conf.Apply(input)
 }
+
+type generateConfig struct {
+	DefaultConfig
+}
+
+func (c generateConfig) Generate(input Object) (Object, error) {
+	return v.Object{
+		v.String("generated"): input,
+	}, nil
+}
+
+func TestApplyOverwrittenGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := generateConfig{*CreateDefaultConfig("12345", dir, dir, 0)}
+
+	_, err = Apply(conf, v.String("foo"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := conf.Store.GetState()
+	want := v.Object{
+		v.String("generated"): v.String("foo"),
+	}
+
+	if !v.IsEqual(got, want) {
+		t.Errorf("Apply() saved %v, want %v", got, want)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	defer os.Unsetenv(InputEnv)
+
+	os.Setenv(InputEnv, `{"replicas": 2}`)
+
+	got, err := ReadInput()
+	want := v.Object{
+		v.String("replicas"): v.Integer(2),
+	}
+
+	if err != nil || !v.IsEqual(got, want) {
+		t.Errorf("ReadInput() == %v, %v, want %v", got, err, want)
+	}
+
+	// injection syntax in the input is text
+	os.Setenv(InputEnv, `{"tpl": "hello {{name}}", "nested": {"a": {"b": 1}}}`)
+
+	got, err = ReadInput()
+	want = v.Object{
+		v.String("tpl"): v.String("hello {{name}}"),
+		v.String("nested"): v.Object{
+			v.String("a"): v.Object{
+				v.String("b"): v.Integer(1),
+			},
+		},
+	}
+
+	if err != nil || !v.IsEqual(got, want) {
+		t.Errorf("ReadInput() == %v, %v, want %v", got, err, want)
+	}
+
+	os.Unsetenv(InputEnv)
+
+	got, err = ReadInput()
+
+	if err != nil || got != nil {
+		t.Errorf("ReadInput() == %v, %v, want nil", got, err)
+	}
+}
+
+func TestPlanOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := generateConfig{*CreateDefaultConfig("12345", dir, dir, 0)}
+
+	q, err := PlanOnly(conf, v.String("foo"))
+
+	if err != nil || q.Len() != 1 || q.Peek().Op != plan.CREATE {
+		t.Fatalf("PlanOnly() == %v, %v", q, err)
+	}
+
+	// nothing is saved
+	if state, _ := conf.Store.GetState(); state != nil {
+		t.Errorf("PlanOnly() saved %v", state)
+	}
+}
+
+type failConfig struct {
+	DefaultConfig
+}
+
+func (c failConfig) Run(op *plan.Operation) error {
+	if op.Path.String() == "[fail]" {
+		return errors.New("run failed")
+	}
+
+	return nil
+}
+
+func TestApplyRunFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := failConfig{*CreateDefaultConfig("12345", dir, dir, 0)}
+	conf.ExecOptions.Parallelism = 4
+	conf.OnFailure = RollbackOnFailure
+
+	old := v.Object{v.String("ok"): v.Integer(1)}
+	conf.Store.WriteState(old)
+
+	_, err = Apply(conf, v.Object{
+		v.String("ok"):   v.Integer(2),
+		v.String("fail"): v.Integer(1),
+	})
+
+	var runErr *RunError
+
+	if !errors.As(err, &runErr) || runErr.Op.Path.String() != "[fail]" || runErr.Stage != "run" {
+		t.Errorf("Apply() returned %v, want a RunError for [fail]", err)
+	}
+
+	if state, _ := conf.Store.GetState(); !v.IsEqual(state, old) {
+		t.Errorf("Apply() saved %v after a failed operation", state)
+	}
+}
+
+type rollbackConfig struct {
+	DefaultConfig
+
+	mu  *sync.Mutex
+	ran *[]string
+}
+
+func (c rollbackConfig) Run(op *plan.Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	*c.ran = append(*c.ran, fmt.Sprintf("%s %s %v %v", op.Op, op.Path, op.Old, op.New))
+
+	if op.Path.String() == "[fail]" {
+		return errors.New("run failed")
+	}
+
+	return nil
+}
+
+type failurePolicyTest struct {
+	name   string
+	policy FailurePolicy
+	ran    []string
+	state  Object
+}
+
+var failurePolicyTests = []failurePolicyTest{
+	failurePolicyTest{
+		name:   "Rollback",
+		policy: RollbackOnFailure,
+		ran: []string{
+			"UPDATE [ok] 1 2",
+			"CREATE [fail] <nil> 1",
+			"UPDATE [ok] 2 1",
+		},
+		state: v.Object{v.String("ok"): v.Integer(1)},
+	},
+	failurePolicyTest{
+		name:   "Save partial state",
+		policy: SaveOnFailure,
+		ran: []string{
+			"UPDATE [ok] 1 2",
+			"CREATE [fail] <nil> 1",
+		},
+		state: v.Object{v.String("ok"): v.Integer(2)},
+	},
+	failurePolicyTest{
+		name: "Default policy",
+		ran: []string{
+			"UPDATE [ok] 1 2",
+			"CREATE [fail] <nil> 1",
+		},
+		state: v.Object{v.String("ok"): v.Integer(2)},
+	},
+}
+
+func TestApplyFailurePolicy(t *testing.T) {
+	for _, test := range failurePolicyTests {
+		dir, err := ioutil.TempDir("", "porter")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer os.RemoveAll(dir)
+
+		var ran []string
+
+		conf := rollbackConfig{*CreateDefaultConfig("12345", dir, dir, 0), &sync.Mutex{}, &ran}
+		conf.OnFailure = test.policy
+		conf.Store.WriteState(v.Object{v.String("ok"): v.Integer(1)})
+
+		_, err = Apply(conf, v.Object{
+			v.String("ok"):   v.Integer(2),
+			v.String("fail"): v.Integer(1),
+		})
+
+		if err == nil {
+			t.Errorf("Failed on: %s, Apply() did not fail", test.name)
+		}
+
+		if len(ran) != len(test.ran) {
+			t.Errorf("Failed on: %s, ran %v, want %v", test.name, ran, test.ran)
+		} else {
+			for i := range ran {
+				if ran[i] != test.ran[i] {
+					t.Errorf("Failed on: %s, ran %v, want %v", test.name, ran, test.ran)
+					break
+				}
+			}
+		}
+
+		if state, _ := conf.Store.GetState(); !v.IsEqual(state, test.state) {
+			t.Errorf("Failed on: %s, saved %v, want %v", test.name, state, test.state)
+		}
+	}
+}
+
+type checkpointConfig struct {
+	DefaultConfig
+
+	states *[]Object
+}
+
+func (c checkpointConfig) Run(op *plan.Operation) error {
+	state, err := c.Store.GetState()
+
+	*c.states = append(*c.states, state)
+
+	return err
+}
+
+func TestApplyCheckpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var states []Object
+
+	conf := checkpointConfig{*CreateDefaultConfig("12345", dir, dir, 0), &states}
+
+	old := v.Object{v.String("a"): v.Integer(1)}
+	conf.Store.WriteState(old)
+
+	Apply(conf, v.Object{
+		v.String("a"): v.Integer(2),
+		v.String("b"): v.Integer(2),
+	})
+
+	// each operation sees the operations that ran before it in the state
+	expected := []Object{
+		old,
+		v.Object{v.String("a"): v.Integer(2)},
+	}
+
+	if len(states) != len(expected) {
+		t.Fatalf("Run() was called %d times, want %d", len(states), len(expected))
+	}
+
+	for i := range states {
+		if !v.IsEqual(states[i], expected[i]) {
+			t.Errorf("Run() %d saw state %v, want %v", i, states[i], expected[i])
+		}
+	}
+
+	// the state before the plan is the only backup
+	backups, err := conf.Store.GetAllBackups()
+
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Apply() wrote backups %v, want 1", backups)
+	}
+
+	backup, _ := conf.Store.GetBackup(backups[0].ID)
+
+	if !v.IsEqual(backup, old) {
+		t.Errorf("Apply() backed up %v, want %v", backup, old)
+	}
+}
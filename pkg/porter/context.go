@@ -0,0 +1,147 @@
+package porter
+
+import (
+	"context"
+	"time"
+
+	"github.com/porterdev/ego/internal/plan"
+)
+
+// ContextConfig is a variant of Config whose methods take a context.Context, so
+// that the application loop can be cancelled, e.g. when the configuration
+// program is interrupted, and so that operations can time out. See
+// ApplyContext.
+//
+// A Config is used as a ContextConfig with AdaptConfig. DefaultConfig does not
+// implement ContextConfig: a type that embeds DefaultConfig implements the
+// context variants of the methods that it overwrites, e.g. RunContext() instead
+// of Run(), and is adapted with AdaptConfig.
+type ContextConfig interface {
+	DataContext(ctx context.Context, input Object) (Object, error)
+	GenerateContext(ctx context.Context, input Object) (Object, error)
+	Plan(old Object, new Object) (*plan.OpQueue, error)
+	RunContext(ctx context.Context, op *plan.Operation) error
+	ValidateContext(ctx context.Context, op *plan.Operation) error
+	SaveContext(ctx context.Context, v Object) error
+}
+
+// AdaptConfig returns a ContextConfig that calls the methods of a Config. If the
+// Config implements the context variant of a method, e.g. RunContext(), the
+// variant is called instead. Methods without a context cannot be cancelled once
+// they are called, but Data(), Generate() and Run() are not called once the
+// context is done. Validate() and Save() are still called, since they complete
+// an operation that ran or save the state of the operations that ran.
+func AdaptConfig(c Config) ContextConfig {
+	if cc, ok := c.(ContextConfig); ok {
+		return cc
+	}
+
+	return configAdapter{c}
+}
+
+// configAdapter adapts a Config to a ContextConfig, see AdaptConfig
+type configAdapter struct {
+	c Config
+}
+
+// DataContext calls DataContext() or Data().
+func (a configAdapter) DataContext(ctx context.Context, input Object) (Object, error) {
+	if c, ok := a.c.(interface {
+		DataContext(context.Context, Object) (Object, error)
+	}); ok {
+		return c.DataContext(ctx, input)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.c.Data(input)
+}
+
+// GenerateContext calls GenerateContext() or Generate().
+func (a configAdapter) GenerateContext(ctx context.Context, input Object) (Object, error) {
+	if c, ok := a.c.(interface {
+		GenerateContext(context.Context, Object) (Object, error)
+	}); ok {
+		return c.GenerateContext(ctx, input)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.c.Generate(input)
+}
+
+// Plan calls Plan().
+func (a configAdapter) Plan(old Object, new Object) (*plan.OpQueue, error) {
+	return a.c.Plan(old, new)
+}
+
+// RunContext calls RunContext() or Run().
+func (a configAdapter) RunContext(ctx context.Context, op *plan.Operation) error {
+	if c, ok := a.c.(interface {
+		RunContext(context.Context, *plan.Operation) error
+	}); ok {
+		return c.RunContext(ctx, op)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.c.Run(op)
+}
+
+// ValidateContext calls ValidateContext() or Validate().
+func (a configAdapter) ValidateContext(ctx context.Context, op *plan.Operation) error {
+	if c, ok := a.c.(interface {
+		ValidateContext(context.Context, *plan.Operation) error
+	}); ok {
+		return c.ValidateContext(ctx, op)
+	}
+
+	return a.c.Validate(op)
+}
+
+// SaveContext calls SaveContext() or Save().
+func (a configAdapter) SaveContext(ctx context.Context, v Object) error {
+	if c, ok := a.c.(interface {
+		SaveContext(context.Context, Object) error
+	}); ok {
+		return c.SaveContext(ctx, v)
+	}
+
+	return a.c.Save(v)
+}
+
+// defaults returns the DefaultConfig embedded in the adapted Config
+func (a configAdapter) defaults() DefaultConfig {
+	return defaultsOf(a.c)
+}
+
+// detachedContext is a context with the values of its parent that is never
+// cancelled
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// detach returns a context with the values of ctx that is not cancelled when
+// ctx is, so that the state is saved and failed plans are rolled back even if
+// the application loop was interrupted
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
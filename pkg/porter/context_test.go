@@ -0,0 +1,94 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/porterdev/ego/internal/plan"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// contextConfig only implements the context variant of Run
+type contextConfig struct {
+	DefaultConfig
+
+	cancel context.CancelFunc
+}
+
+func (c contextConfig) RunContext(ctx context.Context, op *plan.Operation) error {
+	switch op.Path.String() {
+	case "[hang]":
+		<-ctx.Done()
+		return ctx.Err()
+	case "[interrupt]":
+		c.cancel()
+	}
+
+	return nil
+}
+
+func TestApplyContextTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := contextConfig{DefaultConfig: *CreateDefaultConfig("12345", dir, dir, 0)}
+	conf.ExecOptions.Timeout = 10 * time.Millisecond
+	conf.Store.WriteState(v.Object{})
+
+	_, err = ApplyContext(context.Background(), AdaptConfig(conf), v.Object{
+		v.String("hang"): v.Integer(1),
+	})
+
+	var runErr *RunError
+
+	if !errors.As(err, &runErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ApplyContext() returned %v, want a RunError for the timeout", err)
+	}
+}
+
+func TestApplyContextInterrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conf := contextConfig{*CreateDefaultConfig("12345", dir, dir, 0), cancel}
+	conf.Store.WriteState(v.Object{})
+
+	// the operations are run in key order, and the operations after [interrupt]
+	// do not run
+	_, err = ApplyContext(ctx, AdaptConfig(conf), v.Object{
+		v.String("a"):         v.Integer(1),
+		v.String("interrupt"): v.Integer(1),
+		v.String("z"):         v.Integer(1),
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ApplyContext() returned %v, want context.Canceled", err)
+	}
+
+	// the operations that ran are saved, not rolled back
+	expected := v.Object{
+		v.String("a"):         v.Integer(1),
+		v.String("interrupt"): v.Integer(1),
+	}
+
+	if state, _ := conf.Store.GetState(); !v.IsEqual(state, expected) {
+		t.Errorf("ApplyContext() saved %v, want %v", state, expected)
+	}
+}
@@ -0,0 +1,103 @@
+package porter
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/porterdev/ego/internal/plan"
+)
+
+// ErrNoStore is returned by the functions that require a configuration with a
+// Store, such as saved plans
+var ErrNoStore = errors.New("Saved plans require a configuration with a Store")
+
+// ErrStateChanged is returned by ApplyPlan when the stored state has changed
+// since the plan was made
+var ErrStateChanged = errors.New("State has changed since the plan was made, create a new plan")
+
+type (
+	// StoreError is returned when a Store fails to read or write the state or a
+	// backup of a configuration. Path is the file that was read or written, if
+	// any.
+	StoreError struct {
+		ID   string
+		Op   string // e.g. "read state"
+		Path string
+		Err  error
+	}
+
+	// PlanError is returned when a plan cannot be made for a configuration.
+	// Stage is the step of the application loop that failed: "data",
+	// "generate", "plan" or "order".
+	PlanError struct {
+		ID    string
+		Stage string
+		Err   error
+	}
+
+	// RunError is returned when an operation of a plan fails. Stage is "run" if
+	// Config.Run() failed, or "validate" if Config.Validate() failed. If the
+	// method panicked, Err is a *plan.PanicError with the stack trace.
+	RunError struct {
+		ID    string
+		Op    *plan.Operation
+		Stage string
+		Err   error
+	}
+)
+
+// Error describes the failed store operation.
+func (e *StoreError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s failed: %v", e.ID, e.Op, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s %s failed: %v", e.ID, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// Error describes the failed stage.
+func (e *PlanError) Error() string {
+	return fmt.Sprintf("%s: %s failed: %v", e.ID, e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PlanError) Unwrap() error {
+	return e.Err
+}
+
+// Error describes the failed operation.
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%s: %s %s failed: %v", e.ID, e.Stage, e.Op.ToString(), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// storeError wraps an error of the Store of a configuration in a StoreError,
+// unless it is already one
+func storeError(id string, op string, path string, err error) error {
+	var storeErr *StoreError
+
+	if err == nil || errors.As(err, &storeErr) {
+		return err
+	}
+
+	return &StoreError{ID: id, Op: op, Path: path, Err: err}
+}
+
+// catch recovers a panic and stores it in err as a *plan.PanicError with the
+// stack trace, so that a panic in a method of a Config, e.g. Run(), including a
+// runtime error, is returned as an error. catch must be deferred.
+func catch(err *error) {
+	if r := recover(); r != nil {
+		*err = &plan.PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,109 @@
+package porter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/porterdev/ego/internal/plan"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+var errGenerate = errors.New("generate failed")
+
+type generateErrorConfig struct {
+	DefaultConfig
+}
+
+func (c generateErrorConfig) Generate(input Object) (Object, error) {
+	return nil, errGenerate
+}
+
+type panicConfig struct {
+	DefaultConfig
+}
+
+func (c panicConfig) Run(op *plan.Operation) error {
+	panic(errors.New("run panicked"))
+}
+
+type runtimePanicConfig struct {
+	DefaultConfig
+}
+
+func (c runtimePanicConfig) Run(op *plan.Operation) error {
+	var m map[string]int
+	m["a"] = 1
+
+	return nil
+}
+
+func TestApplyErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	input := v.Object{v.String("a"): v.Integer(1)}
+
+	// errors of the Config are wrapped in a PlanError
+	_, err = Apply(generateErrorConfig{*CreateDefaultConfig("12345", dir, dir, 0)}, input)
+
+	var planErr *PlanError
+
+	if !errors.As(err, &planErr) || planErr.Stage != "generate" || !errors.Is(err, errGenerate) {
+		t.Errorf("Apply() returned %v, want a PlanError for generate", err)
+	}
+
+	// panics in Run are returned as a RunError
+	_, err = Apply(panicConfig{*CreateDefaultConfig("12345", dir, dir, 0)}, input)
+
+	var runErr *RunError
+	var storeErr *StoreError
+
+	if !errors.As(err, &runErr) || runErr.Op.Op != plan.CREATE || runErr.Err.Error() != "run panicked" {
+		t.Errorf("Apply() returned %v, want a RunError for the panic", err)
+	}
+
+	// runtime errors in Run are returned as a RunError with the stack trace, and
+	// the lock is released
+	conf, err := NewDefaultConfig("12345", dir, dir, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Apply(runtimePanicConfig{*conf}, input)
+
+	var panicErr *plan.PanicError
+
+	if !errors.As(err, &runErr) || !errors.As(err, &panicErr) || len(panicErr.Stack) == 0 {
+		t.Errorf("Apply() returned %v, want a RunError for the runtime error", err)
+	}
+
+	if info, err := conf.Store.LockInfo(); info != nil || err != nil {
+		t.Errorf("Apply() left the state locked, %v, %v", info, err)
+	}
+
+	if _, err := NewDefaultConfig("12345", filepath.Join(dir, "missing"), dir, 0); !errors.As(err, &storeErr) {
+		t.Errorf("NewDefaultConfig() returned %v for a missing directory, want a StoreError", err)
+	}
+
+	// errors of the Store are wrapped in a StoreError
+	conf = CreateDefaultConfig("12345", dir, dir, 0)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "state_12345.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Apply(conf, input)
+
+	if !errors.As(err, &storeErr) || storeErr.Op != "decode state" || !errors.As(err, &planErr) {
+		t.Errorf("Apply() returned %v, want a StoreError within a PlanError", err)
+	}
+}
@@ -1,7 +1,9 @@
 package porter
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 // TODO -- move these into an internal "utils" directory, together with
@@ -19,4 +21,56 @@ func IsDirectory(path string) bool {
 	return !os.IsNotExist(err) && info.IsDir()
 }
 
+// writeFileAtomic replaces a file with data: data is written to a temporary
+// file in the same directory, synced to disk, and renamed to filename, so that
+// filename holds either its previous contents or data, even after a crash.
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(filename)+".tmp-")
+
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// syncDir syncs a directory to disk, so that a rename in the directory is
+// durable. Errors are ignored, since directories cannot be synced on every
+// platform.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
 // CLEANUP FUNCTION
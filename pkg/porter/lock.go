@@ -1,7 +1,397 @@
 package porter
 
-// Lock is majestic
-type Lock struct {
-	IsLocked bool
-	Process  int
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+	"github.com/porterdev/ego/pkg/json"
+)
+
+// lockRetry is the interval at which Lock retries to acquire a lock that is
+// held by another process
+const lockRetry = 100 * time.Millisecond
+
+// ErrLocked is matched by the error returned when the state of a configuration
+// is locked by another process, see LockError
+var ErrLocked = errors.New("State is locked")
+
+// Locker is implemented by Stores that lock the state of a configuration, so
+// that two processes do not apply the same configuration at the same time.
+//
+// Lock acquires the lock for an operation, e.g. "apply", waiting at most timeout
+// for another process to release it. Unlock releases it. LockInfo returns the
+// holder of the lock, or nil if the state is not locked. ForceUnlock releases a
+// lock held by another process, and requires the ID of the lock so that a lock
+// that was acquired since LockInfo was called is not released by mistake.
+type Locker interface {
+	Lock(operation string, timeout time.Duration) error
+	Unlock() error
+	LockInfo() (*LockInfo, error)
+	ForceUnlock(id string) error
+}
+
+// LockInfo describes the process that holds the lock on the state of a
+// configuration
+type LockInfo struct {
+	ID        string // random ID of the lock
+	PID       int
+	Host      string
+	User      string
+	Time      time.Time
+	Operation string
+}
+
+// LockError is returned when the state of a configuration is locked by another
+// process. It matches ErrLocked with errors.Is.
+type LockError struct {
+	Info *LockInfo
+}
+
+// Error describes the holder of the lock.
+func (e *LockError) Error() string {
+	if e.Info == nil {
+		return ErrLocked.Error()
+	}
+
+	return fmt.Sprintf("%s by %s (process %d on %s) for %s since %s, lock ID %s",
+		ErrLocked, e.Info.User, e.Info.PID, e.Info.Host, e.Info.Operation,
+		e.Info.Time.Format(time.RFC3339), e.Info.ID)
+}
+
+// Is returns true if target is ErrLocked.
+func (e *LockError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// newLockInfo describes the current process holding a lock for an operation
+func newLockInfo(operation string) *LockInfo {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	info := &LockInfo{
+		ID:        hex.EncodeToString(id),
+		PID:       os.Getpid(),
+		Host:      hostname(),
+		User:      os.Getenv("USER"),
+		Time:      time.Now().UTC(),
+		Operation: operation,
+	}
+
+	if u, err := user.Current(); err == nil {
+		info.User = u.Username
+	}
+
+	return info
+}
+
+// hostname returns the name of the host, or an empty string if it is unknown
+func hostname() string {
+	host, _ := os.Hostname()
+
+	return host
+}
+
+// isStale returns true if the holder of a lock is a process that no longer runs
+// on this host, i.e. it stopped without releasing the lock
+func (info *LockInfo) isStale() bool {
+	return info.Host != "" && info.Host == hostname() && !processAlive(info.PID)
+}
+
+// encode encodes the lock info as JSON
+func (info *LockInfo) encode() ([]byte, error) {
+	obj := v.Object{
+		v.String("id"):        v.String(info.ID),
+		v.String("pid"):       v.Integer(info.PID),
+		v.String("host"):      v.String(info.Host),
+		v.String("user"):      v.String(info.User),
+		v.String("time"):      v.String(info.Time.Format(time.RFC3339Nano)),
+		v.String("operation"): v.String(info.Operation),
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf, stateEncoding).Encode(obj); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeLockInfo decodes lock info encoded by LockInfo.encode
+func decodeLockInfo(dat []byte) (*LockInfo, error) {
+	res, err := json.ParseExact(dat)
+
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := res.(v.Object)
+
+	if !ok {
+		return nil, errors.New("Lock file is not an object")
+	}
+
+	info := &LockInfo{}
+
+	fields := map[string]*string{
+		"id":        &info.ID,
+		"host":      &info.Host,
+		"user":      &info.User,
+		"operation": &info.Operation,
+	}
+
+	for key, field := range fields {
+		str, _ := obj[v.String(key)].(v.String)
+		*field = string(str)
+	}
+
+	pid, _ := obj[v.String("pid")].(v.Integer)
+	info.PID = int(pid)
+
+	ts, _ := obj[v.String("time")].(v.String)
+	info.Time, _ = time.Parse(time.RFC3339Nano, string(ts))
+
+	return info, nil
+}
+
+// lockFile returns the path of the lock file of the state
+func (s *LocalStore) lockFile() string {
+	return filepath.Join(s.StateDir, "state_"+s.ID+".lock")
+}
+
+// Lock acquires the lock on the state, by locking the lock file with flock
+// and writing the LockInfo of the current process to it. If another process
+// holds the lock, Lock retries until timeout expires, and then returns a
+// StoreError that wraps a *LockError. A lock held by a process that no longer
+// runs on this host is stale, and is taken over.
+func (s *LocalStore) Lock(operation string, timeout time.Duration) error {
+	filename := s.lockFile()
+
+	if s.lock != nil {
+		return &StoreError{ID: s.ID, Op: "lock state", Path: filename, Err: errors.New("State is already locked by this store")}
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, holder, err := s.tryLock()
+
+		if err != nil {
+			return &StoreError{ID: s.ID, Op: "lock state", Path: filename, Err: err}
+		}
+
+		if f != nil {
+			return s.writeLock(f, operation)
+		}
+
+		// the lock file was removed since it was opened, retry immediately
+		if holder == nil {
+			continue
+		}
+
+		remaining := time.Until(deadline)
+
+		if remaining <= 0 {
+			return &StoreError{ID: s.ID, Op: "lock state", Path: filename, Err: &LockError{Info: holder}}
+		}
+
+		if remaining > lockRetry {
+			remaining = lockRetry
+		}
+
+		time.Sleep(remaining)
+	}
+}
+
+// tryLock tries to lock the lock file once. It returns the locked file, or the
+// holder of the lock if it is held by another process. If neither is returned,
+// the lock file was removed while it was being locked.
+func (s *LocalStore) tryLock() (*os.File, *LockInfo, error) {
+	filename := s.lockFile()
+
+	f, err := openLockFile(filename)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locked, err := flock(f)
+
+	if err != nil || !locked {
+		f.Close()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		holder, err := s.LockInfo()
+
+		if holder == nil && err == nil {
+			holder = &LockInfo{}
+		}
+
+		return nil, holder, err
+	}
+
+	// the lock file was removed by Unlock or ForceUnlock after it was opened
+	if !isLockFile(f, filename) {
+		funlock(f)
+		f.Close()
+
+		return nil, nil, nil
+	}
+
+	// a lock file with info was not unlocked by its holder
+	dat, err := ioutil.ReadAll(f)
+
+	if err == nil && len(dat) > 0 {
+		holder, err := decodeLockInfo(dat)
+
+		if err == nil && !holder.isStale() {
+			funlock(f)
+			f.Close()
+
+			return nil, holder, nil
+		}
+
+		s.Logger.Log(WARNING, s.ID, "taking over stale lock", filename)
+	}
+
+	return f, nil, nil
+}
+
+// writeLock writes the LockInfo of the current process to a locked lock file
+func (s *LocalStore) writeLock(f *os.File, operation string) error {
+	info := newLockInfo(operation)
+
+	dat, err := info.encode()
+
+	if err == nil {
+		err = f.Truncate(0)
+	}
+
+	if err == nil {
+		_, err = f.WriteAt(dat, 0)
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if err != nil {
+		funlock(f)
+		f.Close()
+
+		return &StoreError{ID: s.ID, Op: "lock state", Path: s.lockFile(), Err: err}
+	}
+
+	s.lock = f
+
+	return nil
+}
+
+// Unlock releases the lock on the state, and removes the lock file.
+func (s *LocalStore) Unlock() error {
+	filename := s.lockFile()
+
+	if s.lock == nil {
+		return &StoreError{ID: s.ID, Op: "unlock state", Path: filename, Err: errors.New("State is not locked by this store")}
+	}
+
+	f := s.lock
+	s.lock = nil
+
+	defer f.Close()
+
+	// the lock file may have been replaced after a ForceUnlock
+	if isLockFile(f, filename) {
+		if err := os.Remove(filename); err != nil {
+			funlock(f)
+			return &StoreError{ID: s.ID, Op: "unlock state", Path: filename, Err: err}
+		}
+	}
+
+	if err := funlock(f); err != nil {
+		return &StoreError{ID: s.ID, Op: "unlock state", Path: filename, Err: err}
+	}
+
+	return nil
+}
+
+// LockInfo returns the holder of the lock on the state, or nil if the state is
+// not locked.
+func (s *LocalStore) LockInfo() (*LockInfo, error) {
+	filename := s.lockFile()
+
+	dat, err := ioutil.ReadFile(filename)
+
+	if os.IsNotExist(err) || (err == nil && len(dat) == 0) {
+		return nil, nil
+	} else if err != nil {
+		return nil, &StoreError{ID: s.ID, Op: "read lock", Path: filename, Err: err}
+	}
+
+	info, err := decodeLockInfo(dat)
+
+	if err != nil {
+		return nil, &StoreError{ID: s.ID, Op: "decode lock", Path: filename, Err: err}
+	}
+
+	return info, nil
+}
+
+// ForceUnlock releases the lock on the state held by another process, by
+// removing the lock file. id must be the ID of the lock.
+func (s *LocalStore) ForceUnlock(id string) error {
+	filename := s.lockFile()
+
+	info, err := s.LockInfo()
+
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return &StoreError{ID: s.ID, Op: "force unlock state", Path: filename, Err: errors.New("State is not locked")}
+	}
+
+	if info.ID != id {
+		return &StoreError{
+			ID:   s.ID,
+			Op:   "force unlock state",
+			Path: filename,
+			Err:  fmt.Errorf("Lock ID %s does not match the ID of the lock, %s", id, info.ID),
+		}
+	}
+
+	if err := os.Remove(filename); err != nil {
+		return &StoreError{ID: s.ID, Op: "force unlock state", Path: filename, Err: err}
+	}
+
+	s.Logger.Log(WARNING, s.ID, "forced unlock of lock", id, "held by process", info.PID, "on", info.Host)
+
+	return nil
+}
+
+// isLockFile returns true if f is the file at filename, i.e. if the lock file
+// has not been removed or replaced since it was opened
+func isLockFile(f *os.File, filename string) bool {
+	fi1, err := f.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	fi2, err := os.Stat(filename)
+
+	return err == nil && os.SameFile(fi1, fi2)
 }
@@ -0,0 +1,159 @@
+package porter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// initLockStores is a helper method to create two stores for the same state
+func initLockStores(t *testing.T) (string, *LocalStore, *LocalStore) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s1, _ := NewLocalStore("12345", NewLogger(ERROR), dir, dir)
+	s2, _ := NewLocalStore("12345", NewLogger(ERROR), dir, dir)
+
+	return dir, s1, s2
+}
+
+func TestLock(t *testing.T) {
+	dir, s1, s2 := initLockStores(t)
+	defer os.RemoveAll(dir)
+
+	if err := s1.Lock("apply", 0); err != nil {
+		t.Fatalf("Lock() failed: %v", err)
+	}
+
+	info, err := s2.LockInfo()
+
+	if err != nil || info == nil || info.PID != os.Getpid() || info.Operation != "apply" {
+		t.Errorf("LockInfo() == %v, %v", info, err)
+	}
+
+	// the second store waits for the timeout
+	start := time.Now()
+	err = s2.Lock("apply", 150*time.Millisecond)
+
+	var lockErr *LockError
+
+	if !errors.Is(err, ErrLocked) || !errors.As(err, &lockErr) || lockErr.Info.ID != info.ID {
+		t.Errorf("Lock() returned %v, want a LockError", err)
+	}
+
+	if time.Since(start) < 150*time.Millisecond {
+		t.Errorf("Lock() did not wait for the timeout")
+	}
+
+	if err := s1.Unlock(); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+
+	if err := s2.Lock("apply", 0); err != nil {
+		t.Errorf("Lock() failed after Unlock(): %v", err)
+	}
+
+	s2.Unlock()
+
+	if info, _ := s1.LockInfo(); info != nil {
+		t.Errorf("LockInfo() == %v after Unlock()", info)
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	dir, s1, s2 := initLockStores(t)
+	defer os.RemoveAll(dir)
+
+	s1.Lock("apply", 0)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s1.Unlock()
+	}()
+
+	if err := s2.Lock("apply", time.Second); err != nil {
+		t.Errorf("Lock() did not wait for the lock to be released: %v", err)
+	}
+
+	s2.Unlock()
+}
+
+func TestLockStale(t *testing.T) {
+	dir, s1, _ := initLockStores(t)
+	defer os.RemoveAll(dir)
+
+	// a process that no longer runs on this host
+	stale := newLockInfo("apply")
+	stale.PID = 1 << 30
+
+	dat, _ := stale.encode()
+	ioutil.WriteFile(s1.lockFile(), dat, 0644)
+
+	if err := s1.Lock("apply", 0); err != nil {
+		t.Errorf("Lock() did not take over a stale lock: %v", err)
+	}
+
+	s1.Unlock()
+}
+
+func TestForceUnlock(t *testing.T) {
+	dir, s1, s2 := initLockStores(t)
+	defer os.RemoveAll(dir)
+
+	s1.Lock("apply", 0)
+
+	info, _ := s2.LockInfo()
+
+	if err := s2.ForceUnlock("0"); err == nil {
+		t.Errorf("ForceUnlock() released a lock with another ID")
+	}
+
+	if err := s2.ForceUnlock(info.ID); err != nil {
+		t.Fatalf("ForceUnlock() failed: %v", err)
+	}
+
+	if err := s2.Lock("apply", 0); err != nil {
+		t.Errorf("Lock() failed after ForceUnlock(): %v", err)
+	}
+
+	// the first store does not release the lock of the second
+	s1.Unlock()
+
+	if info, _ := s1.LockInfo(); info == nil {
+		t.Errorf("Unlock() released a lock acquired after ForceUnlock()")
+	}
+
+	s2.Unlock()
+}
+
+func TestApplyLocked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := CreateDefaultConfig("12345", dir, dir, 0)
+
+	other, _ := NewLocalStore("12345", NewLogger(ERROR), dir, dir)
+	other.Lock("apply", 0)
+
+	if _, err := Apply(conf, v.Object{}); !errors.Is(err, ErrLocked) {
+		t.Errorf("Apply() returned %v, want ErrLocked", err)
+	}
+
+	other.Unlock()
+
+	if _, err := Apply(conf, v.Object{}); err != nil {
+		t.Errorf("Apply() failed after Unlock(): %v", err)
+	}
+}
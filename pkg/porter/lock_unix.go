@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package porter
+
+import (
+	"os"
+	"syscall"
+)
+
+// openLockFile opens the lock file for reading and writing, creating it if it
+// does not exist
+func openLockFile(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+// flock tries to acquire an exclusive advisory lock on a file without
+// blocking, and returns false if another open file holds the lock
+func flock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// funlock releases the lock acquired by flock
+func funlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// processAlive returns true if a process with the given PID runs on this host
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+
+	if err != nil {
+		return false
+	}
+
+	err = p.Signal(syscall.Signal(0))
+
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,114 @@
+//go:build windows
+// +build windows
+
+package porter
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// lockRange returns the byte range locked by flock. Locks on Windows are
+// mandatory, so the range lies past the end of the lock file, and the LockInfo
+// in the file can still be read by other processes.
+func lockRange() *syscall.Overlapped {
+	return &syscall.Overlapped{Offset: 0, OffsetHigh: 0x7fffffff}
+}
+
+// openLockFile opens the lock file for reading and writing, creating it if it
+// does not exist. Unlike os.OpenFile, the file is shared for deletion, so that
+// Unlock and ForceUnlock can remove the lock file while it is open.
+func openLockFile(filename string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(filename)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	h, err := syscall.CreateFile(
+		name,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0,
+	)
+
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return os.NewFile(uintptr(h), filename), nil
+}
+
+// flock tries to acquire an exclusive lock on a file with LockFileEx without
+// blocking, and returns false if another open file holds the lock
+func flock(f *os.File) (bool, error) {
+	r, _, err := procLockFileEx.Call(
+		f.Fd(),
+		lockfileExclusiveLock|lockfileFailImmediately,
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(lockRange())),
+	)
+
+	if r != 0 {
+		return true, nil
+	}
+
+	if err == errorLockViolation {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// funlock releases the lock acquired by flock
+func funlock(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRange())))
+
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// processAlive returns true if a process with the given PID runs on this host
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+
+	if err != nil {
+		// the process exists, but belongs to another user
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+
+	return code == stillActive
+}
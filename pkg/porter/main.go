@@ -0,0 +1,155 @@
+package porter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/porterdev/ego/internal/plan"
+	"github.com/porterdev/ego/pkg/json"
+)
+
+// InputEnv is the environment variable that porter apply uses to pass the
+// input of a configuration program, encoded as JSON
+const InputEnv = "PORTER_INPUT"
+
+// ModeEnv is the environment variable that selects what a configuration
+// program does: ModeApply or ModePlan. The default mode is ModeApply.
+const ModeEnv = "PORTER_MODE"
+
+// PlanFileEnv is the environment variable that holds the path of a saved plan.
+// In ModePlan, the plan is saved to this path; in ModeApply, the saved plan is
+// applied instead of a new plan.
+const PlanFileEnv = "PORTER_PLAN_FILE"
+
+// ProgramEnv is the environment variable that holds the path of the source of
+// a configuration program, which is recorded in saved plans
+const ProgramEnv = "PORTER_PROGRAM"
+
+// The modes of a configuration program
+const (
+	ModeApply = "apply"
+	ModePlan  = "plan"
+)
+
+// ExitChanges is the exit status of a configuration program in ModePlan when
+// the plan contains changes
+const ExitChanges = 2
+
+// Main is the entry point of a configuration program. It reads the input from
+// InputEnv and runs the application loop for c. If the loop fails, Main prints
+// the error and exits with a non-zero status.
+//
+// If ModeEnv is ModePlan, Main prints the plan instead of applying it, and
+// exits with ExitChanges if the plan contains changes. If PlanFileEnv is set,
+// the plan is also saved to that file in ModePlan, and that saved plan is
+// applied in ModeApply.
+//
+// In ModeApply, the first SIGINT or SIGTERM interrupts the application loop:
+// the operations that are running finish and the partially applied state is
+// saved before Main exits. A second signal terminates the program immediately.
+func Main(c Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			// runtime errors are not logged, so keep their stack trace
+			if _, ok := r.(runtime.Error); ok {
+				panic(r)
+			}
+
+			fmt.Fprintln(os.Stderr, r)
+			os.Exit(1)
+		}
+	}()
+
+	input, err := ReadInput()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading input:", err)
+		os.Exit(1)
+	}
+
+	planFile := os.Getenv(PlanFileEnv)
+
+	switch mode := os.Getenv(ModeEnv); {
+	case (mode == "" || mode == ModeApply) && planFile != "":
+		var p *PlanFile
+
+		if p, err = ReadPlanFile(planFile); err == nil {
+			_, err = ApplyPlanContext(interruptContext(), AdaptConfig(c), p)
+		}
+	case mode == "" || mode == ModeApply:
+		_, err = ApplyContext(interruptContext(), AdaptConfig(c), input)
+	case mode == ModePlan && planFile != "":
+		var p *PlanFile
+
+		if p, err = NewPlanFile(c, input); err == nil {
+			p.Program = os.Getenv(ProgramEnv)
+			err = p.Write(planFile)
+		}
+
+		if err == nil && RenderPlan(os.Stdout, p.Queue(), useColor()).Changes() > 0 {
+			os.Exit(ExitChanges)
+		}
+	case mode == ModePlan:
+		var q *plan.OpQueue
+
+		q, err = PlanOnly(c, input)
+
+		if err == nil && RenderPlan(os.Stdout, q, useColor()).Changes() > 0 {
+			os.Exit(ExitChanges)
+		}
+	default:
+		err = fmt.Errorf("unknown mode %s", mode)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		// print the stack trace of a panic in a method of the Config
+		var panicErr *plan.PanicError
+
+		if errors.As(err, &panicErr) {
+			os.Stderr.Write(panicErr.Stack)
+		}
+
+		os.Exit(1)
+	}
+}
+
+// interruptContext returns a context that is cancelled when the program receives
+// SIGINT or SIGTERM. The default behavior of the signals is then restored, so
+// that a second signal terminates the program.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-sigs
+
+		signal.Reset(os.Interrupt, syscall.SIGTERM)
+		fmt.Fprintln(os.Stderr, "Interrupted, waiting for the running operations to finish...")
+
+		cancel()
+	}()
+
+	return ctx
+}
+
+// ReadInput returns the input passed to a configuration program in InputEnv,
+// which is plain JSON without injections. If no input was passed, the input is
+// nil.
+func ReadInput() (Object, error) {
+	src := os.Getenv(InputEnv)
+
+	if src == "" {
+		return nil, nil
+	}
+
+	return json.Parse([]byte(src))
+}
@@ -0,0 +1,307 @@
+package porter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/porterdev/ego/internal/plan"
+	v "github.com/porterdev/ego/internal/value"
+	"github.com/porterdev/ego/pkg/json"
+)
+
+// PlanFileVersion is the version of the plan file format written by
+// PlanFile.Write. ReadPlanFile refuses plan files of any other version.
+const PlanFileVersion = 1
+
+// PlanFile is a plan saved to a file, so that it can be reviewed and applied
+// later. It records the operations of the plan, the configuration that the plan
+// produces, and the hash of the stored state that the plan was computed against.
+type PlanFile struct {
+	Version   int
+	ID        string // ID of the configuration
+	Program   string // path of the configuration program, if known
+	StateHash string // StateHash of the stored state when the plan was made
+	New       Object // configuration saved once the plan is applied
+
+	Ops []*plan.Operation
+}
+
+// NewPlanFile retrieves the current data and generates the new configuration
+// for a Config, and returns the plan between them together with the hash of the
+// state in the Config's Store.
+func NewPlanFile(c Config, input Object) (res *PlanFile, err error) {
+	defer catch(&err)
+
+	d := defaultsOf(c)
+
+	if d.Store == nil {
+		return nil, ErrNoStore
+	}
+
+	state, err := d.Store.GetState()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := StateHash(state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, new, q, err := generatePlan(context.Background(), AdaptConfig(c), input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlanFile{
+		Version:   PlanFileVersion,
+		ID:        d.ID,
+		StateHash: hash,
+		New:       new,
+		Ops:       q.Ops(),
+	}, nil
+}
+
+// Queue returns the operations of the plan as an OpQueue.
+func (p *PlanFile) Queue() *plan.OpQueue {
+	q := plan.NewOpQueue()
+
+	for _, op := range p.Ops {
+		q.Enqueue(op)
+	}
+
+	return q
+}
+
+// Write saves the plan to a file as JSON. Object keys are sorted and indented,
+// so that plans can be reviewed and diffed.
+func (p *PlanFile) Write(filename string) error {
+	ops := v.Array{}
+
+	for _, op := range p.Ops {
+		obj := v.Object{
+			v.String("op"):   v.String(op.Op.String()),
+			v.String("path"): v.String(op.Path.String()),
+			v.String("old"):  op.Old,
+			v.String("new"):  op.New,
+		}
+
+		if len(op.From) > 0 {
+			obj[v.String("from")] = v.String(op.From.String())
+		}
+
+		if op.CreateBeforeDelete {
+			obj[v.String("create_before_delete")] = v.Boolean(true)
+		}
+
+		ops = append(ops, obj)
+	}
+
+	obj := v.Object{
+		v.String("version"):    v.Integer(p.Version),
+		v.String("id"):         v.String(p.ID),
+		v.String("program"):    v.String(p.Program),
+		v.String("state_hash"): v.String(p.StateHash),
+		v.String("new"):        p.New,
+		v.String("operations"): ops,
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf, stateEncoding).Encode(obj); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// ReadPlanFile reads a plan saved by PlanFile.Write.
+func ReadPlanFile(filename string) (*PlanFile, error) {
+	dat, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := json.ParseExact(dat)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid plan file %s: %v", filename, err)
+	}
+
+	obj, ok := res.(v.Object)
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid plan file %s: not an object", filename)
+	}
+
+	version, ok := obj[v.String("version")].(v.Integer)
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid plan file %s: missing version", filename)
+	} else if int(version) != PlanFileVersion {
+		return nil, fmt.Errorf("Unsupported plan file version %d, expected %d", version, PlanFileVersion)
+	}
+
+	p := &PlanFile{
+		Version: int(version),
+		New:     obj[v.String("new")],
+	}
+
+	fields := map[string]*string{
+		"id":         &p.ID,
+		"program":    &p.Program,
+		"state_hash": &p.StateHash,
+	}
+
+	for key, field := range fields {
+		str, ok := obj[v.String(key)].(v.String)
+
+		if !ok {
+			return nil, fmt.Errorf("Invalid plan file %s: missing %s", filename, key)
+		}
+
+		*field = string(str)
+	}
+
+	ops, ok := obj[v.String("operations")].(v.Array)
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid plan file %s: missing operations", filename)
+	}
+
+	for i, val := range ops {
+		op, err := readOperation(val)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid plan file %s: operation %d: %v", filename, i, err)
+		}
+
+		p.Ops = append(p.Ops, op)
+	}
+
+	return p, nil
+}
+
+// readOperation converts an operation written by PlanFile.Write back to an
+// Operation
+func readOperation(val v.Value) (*plan.Operation, error) {
+	obj, ok := val.(v.Object)
+
+	if !ok {
+		return nil, errors.New("not an object")
+	}
+
+	name, ok := obj[v.String("op")].(v.String)
+
+	if !ok {
+		return nil, errors.New("missing op")
+	}
+
+	opType, err := plan.ParseOpType(string(name))
+
+	if err != nil {
+		return nil, err
+	}
+
+	str, ok := obj[v.String("path")].(v.String)
+
+	if !ok {
+		return nil, errors.New("missing path")
+	}
+
+	path, err := v.ParsePath(string(str))
+
+	if err != nil {
+		return nil, err
+	}
+
+	str, _ = obj[v.String("from")].(v.String)
+
+	from, err := v.ParsePath(string(str))
+
+	if err != nil {
+		return nil, err
+	}
+
+	createFirst, _ := obj[v.String("create_before_delete")].(v.Boolean)
+
+	return &plan.Operation{
+		Op:   opType,
+		Path: path,
+		Old:  obj[v.String("old")],
+		New:  obj[v.String("new")],
+		From: from,
+
+		CreateBeforeDelete: bool(createFirst),
+	}, nil
+}
+
+// ApplyPlan runs the operations of a saved plan for a Config, and saves the
+// configuration that the plan produces. ApplyPlan refuses to run the plan if
+// it was made for another configuration, or if the state in the Config's Store
+// has changed since the plan was made, in which case the error is
+// ErrStateChanged. Errors are otherwise returned as by Apply.
+func ApplyPlan(c Config, p *PlanFile) (Object, error) {
+	return ApplyPlanContext(context.Background(), AdaptConfig(c), p)
+}
+
+// ApplyPlanContext runs the operations of a saved plan for a ContextConfig, like
+// ApplyPlan. ctx is used as by ApplyContext.
+func ApplyPlanContext(ctx context.Context, c ContextConfig, p *PlanFile) (res Object, err error) {
+	defer catch(&err)
+
+	d := defaultsOf(c)
+
+	if d.Store == nil {
+		return nil, ErrNoStore
+	}
+
+	if p.ID != d.ID {
+		return nil, fmt.Errorf("Plan was made for configuration %s, not %s", p.ID, d.ID)
+	}
+
+	unlock, err := lockStore(c, ModeApply)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	state, err := d.Store.GetState()
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := StateHash(state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hash != p.StateHash {
+		return nil, ErrStateChanged
+	}
+
+	return execute(ctx, c, state, p.New, p.Queue())
+}
+
+// StateHash returns the SHA-256 hash of a state, computed over its encoding in
+// a state file, e.g. "sha256:2c26b4...".
+func StateHash(state Object) (string, error) {
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf, stateEncoding).Encode(state); err != nil {
+		return "", err
+	}
+
+	return checksum(buf.Bytes()), nil
+}
@@ -0,0 +1,145 @@
+package porter
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/porterdev/ego/internal/plan"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+func TestPlanFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	conf := CreateDefaultConfig("12345", dir, dir, 0)
+	conf.Store.WriteState(v.Object{
+		v.String("hello"): v.String("there"),
+		v.String("old"):   v.Integer(1),
+	})
+
+	p, err := NewPlanFile(conf, v.Object{
+		v.String("hello"): v.String("{{ general }}"),
+		v.String("new"):   v.Array{v.Float(1.5), nil, v.Boolean(true)},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Program = "/src/main.ego"
+	filename := filepath.Join(dir, "test.plan")
+
+	if err := p.Write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ReadPlanFile(filename)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Version != PlanFileVersion || res.ID != "12345" || res.Program != p.Program || res.StateHash != p.StateHash {
+		t.Errorf("Failed on: header, read %+v, wrote %+v", res, p)
+	}
+
+	if !v.IsEqual(res.New, p.New) {
+		t.Errorf("Failed on: new, read %v, wrote %v", res.New, p.New)
+	}
+
+	if len(res.Ops) != len(p.Ops) {
+		t.Fatalf("Failed on: operations, read %d, wrote %d", len(res.Ops), len(p.Ops))
+	}
+
+	for i, op := range res.Ops {
+		if !op.IsEqual(p.Ops[i]) {
+			t.Errorf("Failed on: operation %d, read %v, wrote %v", i, op, p.Ops[i])
+		}
+	}
+}
+
+func TestReadPlanFileVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.plan")
+	ioutil.WriteFile(filename, []byte(`{"version": 99, "operations": []}`), 0644)
+
+	if _, err := ReadPlanFile(filename); err == nil {
+		t.Errorf("ReadPlanFile() read a plan file with an unsupported version")
+	}
+}
+
+type recordConfig struct {
+	DefaultConfig
+
+	ran *[]string
+}
+
+func (c recordConfig) Run(op *plan.Operation) error {
+	*c.ran = append(*c.ran, op.ToString())
+	return nil
+}
+
+func TestApplyPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	var ran []string
+
+	conf := recordConfig{*CreateDefaultConfig("12345", dir, dir, 0), &ran}
+	input := v.Object{
+		v.String("hello"): v.String("there"),
+	}
+
+	p, err := NewPlanFile(conf, input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApplyPlan(conf, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ran) != 1 || ran[0] != "0:" {
+		t.Errorf("ApplyPlan() ran %v, want [0:]", ran)
+	}
+
+	got, _ := conf.Store.GetState()
+
+	if !v.IsEqual(got, input) {
+		t.Errorf("ApplyPlan() saved %v, want %v", got, input)
+	}
+
+	// the state has changed since the plan was made
+	if _, err := ApplyPlan(conf, p); !errors.Is(err, ErrStateChanged) {
+		t.Errorf("ApplyPlan() applied a plan made against a different state")
+	}
+
+	// the plan was made for another configuration
+	p.ID = "67890"
+
+	if _, err := ApplyPlan(conf, p); err == nil {
+		t.Errorf("ApplyPlan() applied a plan made for another configuration")
+	}
+}
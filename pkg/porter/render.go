@@ -0,0 +1,141 @@
+package porter
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/porterdev/ego/internal/plan"
+	"github.com/porterdev/ego/pkg/json"
+)
+
+// ANSI escape codes used to colorize plans
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// PlanSummary counts the operations of a plan by type.
+type PlanSummary struct {
+	Create    int
+	Update    int
+	Delete    int
+	Move      int
+	Replace   int
+	Unchanged int
+}
+
+// Changes returns the number of operations that change the configuration.
+func (s PlanSummary) Changes() int {
+	return s.Create + s.Update + s.Delete + s.Move + s.Replace
+}
+
+// String returns a one-line description of the summary.
+func (s PlanSummary) String() string {
+	if s.Changes() == 0 {
+		return "No changes."
+	}
+
+	res := fmt.Sprintf("Plan: %d to create, %d to update, %d to delete", s.Create, s.Update, s.Delete)
+
+	if s.Replace > 0 {
+		res += fmt.Sprintf(", %d to replace", s.Replace)
+	}
+
+	if s.Move > 0 {
+		res += fmt.Sprintf(", %d to move", s.Move)
+	}
+
+	return res + "."
+}
+
+// RenderPlan writes a human-readable listing of the operations in a plan that
+// change the configuration to w, followed by a summary, and returns the
+// summary. If color is true, the listing is colorized with ANSI escape codes.
+// The plan is not modified.
+func RenderPlan(w io.Writer, q *plan.OpQueue, color bool) PlanSummary {
+	var s PlanSummary
+
+	paint := func(code, str string) string {
+		if !color {
+			return str
+		}
+
+		return code + str + colorReset
+	}
+
+	for _, op := range q.Ops() {
+		path := op.Path.String()
+
+		// deleted array elements are shown at their old index
+		if op.Op == plan.DELETE && len(op.From) > 0 {
+			path = op.From.String()
+		}
+
+		if path == "" {
+			path = "(root)"
+		}
+
+		switch op.Op {
+		case plan.CREATE:
+			s.Create++
+			fmt.Fprintf(w, "%s %s: %s\n", paint(colorGreen, "+"), path, paint(colorGreen, renderValue(op.New)))
+		case plan.UPDATE:
+			s.Update++
+			fmt.Fprintf(w, "%s %s: %s -> %s\n", paint(colorYellow, "~"), path, paint(colorRed, renderValue(op.Old)), paint(colorGreen, renderValue(op.New)))
+		case plan.DELETE:
+			s.Delete++
+			fmt.Fprintf(w, "%s %s: %s\n", paint(colorRed, "-"), path, paint(colorRed, renderValue(op.Old)))
+		case plan.REPLACE:
+			s.Replace++
+
+			// the order in which the old value is deleted and the new value created
+			sign := "-/+"
+
+			if op.CreateBeforeDelete {
+				sign = "+/-"
+			}
+
+			fmt.Fprintf(w, "%s %s: %s -> %s\n", paint(colorYellow, sign), path, paint(colorRed, renderValue(op.Old)), paint(colorGreen, renderValue(op.New)))
+		case plan.MOVE:
+			s.Move++
+			fmt.Fprintf(w, "%s %s: moved from %s\n", paint(colorYellow, ">"), path, op.From)
+		default:
+			s.Unchanged++
+		}
+	}
+
+	if s.Changes() > 0 {
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, paint(colorBold, s.String()))
+
+	return s
+}
+
+// renderValue returns the compact JSON form of a value in a plan
+func renderValue(v Object) string {
+	res, err := json.ToJSON(v)
+
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return res
+}
+
+// useColor returns true if plans written to stdout should be colorized: stdout
+// must be a terminal, and the NO_COLOR environment variable must not be set.
+func useColor() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,131 @@
+package porter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/porterdev/ego/internal/plan"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+func TestRenderPlan(t *testing.T) {
+	old := v.Object{
+		v.String("kind"):     v.String("Service"),
+		v.String("replicas"): v.Integer(1),
+		v.String("labels"):   v.Object{},
+	}
+
+	new := v.Object{
+		v.String("kind"):     v.String("Service"),
+		v.String("replicas"): v.Integer(2),
+		v.String("ports"):    v.Array{v.Integer(80)},
+	}
+
+	q := plan.CreateOpQueue(old, new)
+
+	var buf bytes.Buffer
+
+	s := RenderPlan(&buf, q, false)
+
+	want := map[string]bool{
+		"~ [replicas]: 1 -> 2": true,
+		"- [labels]: {}":       true,
+		"+ [ports]: [80]":      true,
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+
+	for _, line := range lines[:3] {
+		if !want[string(line)] {
+			t.Errorf("RenderPlan() unexpected line %q", line)
+		}
+	}
+
+	if got := string(bytes.Join(lines[3:], []byte("\n"))); got != "\nPlan: 1 to create, 1 to update, 1 to delete." {
+		t.Errorf("RenderPlan() unexpected summary %q", got)
+	}
+
+	if s != (PlanSummary{Create: 1, Update: 1, Delete: 1, Unchanged: 1}) || q.Len() != 4 {
+		t.Errorf("RenderPlan() == %v, queue length %d", s, q.Len())
+	}
+}
+
+func TestRenderPlanNoChanges(t *testing.T) {
+	var buf bytes.Buffer
+
+	s := RenderPlan(&buf, plan.CreateOpQueue(v.Integer(1), v.Integer(1)), true)
+
+	if want := colorBold + "No changes." + colorReset + "\n"; buf.String() != want || s.Changes() != 0 {
+		t.Errorf("RenderPlan() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRenderPlanColor(t *testing.T) {
+	var buf bytes.Buffer
+
+	RenderPlan(&buf, plan.CreateOpQueue(nil, v.String("a")), true)
+
+	want := colorGreen + "+" + colorReset + " (root): " + colorGreen + "\"a\"" + colorReset + "\n\n" +
+		colorBold + "Plan: 1 to create, 0 to update, 0 to delete." + colorReset + "\n"
+
+	if buf.String() != want {
+		t.Errorf("RenderPlan() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRenderPlanMove(t *testing.T) {
+	var buf bytes.Buffer
+
+	q := plan.CreateOpQueueOptions(v.Array{v.Integer(1), v.Integer(2)}, v.Array{v.Integer(2), v.Integer(1)}, plan.Options{
+		Arrays: map[string]plan.ArrayOptions{"": plan.ArrayOptions{Diff: plan.LCSDiff}},
+	})
+
+	s := RenderPlan(&buf, q, false)
+
+	want := "> [0]: moved from [1]\n\nPlan: 0 to create, 0 to update, 0 to delete, 1 to move.\n"
+
+	if buf.String() != want || s.Move != 1 {
+		t.Errorf("RenderPlan() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRenderPlanReplace(t *testing.T) {
+	var buf bytes.Buffer
+
+	q := plan.CreateOpQueueOptions(v.Object{v.String("port"): v.String("80")}, v.Object{v.String("port"): v.Integer(80)}, plan.Options{
+		CreateBeforeDelete: []string{"[port]"},
+	})
+
+	s := RenderPlan(&buf, q, false)
+
+	want := "+/- [port]: \"80\" -> 80\n\nPlan: 0 to create, 0 to update, 0 to delete, 1 to replace.\n"
+
+	if buf.String() != want || s.Replace != 1 {
+		t.Errorf("RenderPlan() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRenderPlanStable(t *testing.T) {
+	old := v.Object{}
+	new := v.Object{}
+
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		old[v.String(k)] = v.Object{v.String(k): v.Integer(1), v.String("x"): v.Integer(1)}
+		new[v.String(k+k)] = v.Object{v.String(k): v.Integer(2)}
+	}
+
+	var first bytes.Buffer
+
+	RenderPlan(&first, plan.CreateOpQueue(old, new), false)
+
+	// map iteration order is random, so render the same plan repeatedly
+	for i := 0; i < 20; i++ {
+		var buf bytes.Buffer
+
+		RenderPlan(&buf, plan.CreateOpQueue(old, new), false)
+
+		if !bytes.Equal(buf.Bytes(), first.Bytes()) {
+			t.Fatalf("RenderPlan() wrote %q, then %q", first.String(), buf.String())
+		}
+	}
+}
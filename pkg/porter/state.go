@@ -0,0 +1,171 @@
+package porter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	v "github.com/porterdev/ego/internal/value"
+	"github.com/porterdev/ego/pkg/json"
+)
+
+// FrameworkVersion is the version of the Porter framework, recorded in state
+// files
+const FrameworkVersion = "0.1.0"
+
+// StateFormatVersion is the version of the format of state files written by
+// LocalStore. To change the format, increment StateFormatVersion and add a
+// migration from the previous version to stateMigrations.
+const StateFormatVersion = 1
+
+// ErrStaleSerial is returned by LocalStore when the state was written by
+// another process since the store last read or wrote it
+var ErrStaleSerial = errors.New("State was written by another process since it was read")
+
+// StateMeta is the metadata stored with the state of a configuration. Serial is
+// incremented by each write, and Lineage identifies the history of a state: it
+// is generated when the state is first written, and kept by later writes.
+// Checksum is the StateHash of the state.
+type StateMeta struct {
+	Version          int
+	Serial           int
+	Lineage          string
+	FrameworkVersion string
+	Timestamp        time.Time
+	Checksum         string
+}
+
+// stateMigration upgrades the envelope of a state file from a version to the
+// next version
+type stateMigration func(env v.Object) (v.Object, error)
+
+// stateMigrations maps each format version of state files to the migration to
+// the next version. Version 0 is a state file written before state files were
+// versioned: an envelope with only the checksum and the state, or the state
+// itself.
+var stateMigrations = map[int]stateMigration{
+	0: func(env v.Object) (v.Object, error) {
+		state := env[v.String("state")]
+		sum, ok := env[v.String("checksum")].(v.String)
+
+		// state files without a checksum are not verified
+		if !ok {
+			hash, err := StateHash(state)
+
+			if err != nil {
+				return nil, err
+			}
+
+			sum = v.String(hash)
+		}
+
+		return v.Object{
+			v.String("version"):           v.Integer(1),
+			v.String("serial"):            v.Integer(0),
+			v.String("lineage"):           v.String(""),
+			v.String("framework_version"): v.String(""),
+			v.String("timestamp"):         v.String(""),
+			v.String("checksum"):          sum,
+			v.String("state"):             state,
+		}, nil
+	},
+}
+
+// encodeState encodes a state and its metadata as a state file
+func encodeState(state Object, meta *StateMeta) ([]byte, error) {
+	env := v.Object{
+		v.String("version"):           v.Integer(meta.Version),
+		v.String("serial"):            v.Integer(meta.Serial),
+		v.String("lineage"):           v.String(meta.Lineage),
+		v.String("framework_version"): v.String(meta.FrameworkVersion),
+		v.String("timestamp"):         v.String(meta.Timestamp.Format(time.RFC3339Nano)),
+		v.String("checksum"):          v.String(meta.Checksum),
+		v.String("state"):             state,
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf, stateEncoding).Encode(env); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeState decodes a state file, migrating it to StateFormatVersion, and
+// verifies the state against its checksum. It returns ErrCorruptState if the
+// file cannot be decoded or does not match its checksum.
+func decodeState(dat []byte) (Object, *StateMeta, error) {
+	res, err := json.ParseExact(dat)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrCorruptState, err)
+	}
+
+	env, _ := res.(v.Object)
+	version, _ := env[v.String("version")].(v.Integer)
+	_, hasSum := env[v.String("checksum")].(v.String)
+	_, hasState := env[v.String("state")]
+
+	// state files without an envelope hold the state itself
+	if !hasSum || !hasState {
+		env = v.Object{v.String("state"): res}
+		version = 0
+	}
+
+	if int(version) > StateFormatVersion {
+		return nil, nil, fmt.Errorf("State file format version %d is newer than the supported version %d, upgrade Porter", version, StateFormatVersion)
+	}
+
+	for ; int(version) < StateFormatVersion; version++ {
+		if env, err = stateMigrations[int(version)](env); err != nil {
+			return nil, nil, fmt.Errorf("Migrating state file from version %d failed: %v", version, err)
+		}
+	}
+
+	meta := &StateMeta{Version: int(version)}
+
+	serial, _ := env[v.String("serial")].(v.Integer)
+	meta.Serial = int(serial)
+
+	fields := map[string]*string{
+		"lineage":           &meta.Lineage,
+		"framework_version": &meta.FrameworkVersion,
+		"checksum":          &meta.Checksum,
+	}
+
+	for key, field := range fields {
+		str, _ := env[v.String(key)].(v.String)
+		*field = string(str)
+	}
+
+	ts, _ := env[v.String("timestamp")].(v.String)
+	meta.Timestamp, _ = time.Parse(time.RFC3339Nano, string(ts))
+
+	state := env[v.String("state")]
+
+	sum, err := StateHash(state)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sum != meta.Checksum {
+		return nil, nil, ErrCorruptState
+	}
+
+	return state, meta, nil
+}
+
+// newLineage returns a random (version 4) UUID
+func newLineage() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
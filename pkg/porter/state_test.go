@@ -0,0 +1,230 @@
+package porter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+func TestStateEnvelope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val1 := v.Object{v.String("hello"): v.String("there")}
+	val2 := v.Object{v.String("general"): v.String("kenobi")}
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+
+	store.WriteState(val1)
+	meta1, err := store.GetStateMeta()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta1.Version != StateFormatVersion || meta1.Serial != 1 || meta1.Lineage == "" || meta1.FrameworkVersion != FrameworkVersion {
+		t.Errorf("Failed on first write, %+v", meta1)
+	}
+
+	store.WriteState(val2)
+	meta2, _ := store.GetStateMeta()
+
+	if meta2.Serial != 2 || meta2.Lineage != meta1.Lineage || meta2.Timestamp.Before(meta1.Timestamp) {
+		t.Errorf("Failed on second write, %+v, %+v", meta1, meta2)
+	}
+
+	if hash, _ := StateHash(val2); meta2.Checksum != hash {
+		t.Errorf("Failed on checksum, %s, %s", meta2.Checksum, hash)
+	}
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, val2) {
+		t.Errorf("GetState() == %v, %v, want %v", state, err, val2)
+	}
+}
+
+func TestStateInjectionSyntax(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	want := v.Object{
+		v.String("cmd"):  v.String("echo {{ .Values.tag }}"),
+		v.String("tmpl"): v.String("{{a}}-{{b"),
+	}
+
+	// a state file without a checksum, as written by older versions
+	ioutil.WriteFile(filename, []byte("{\n    \"cmd\": \"echo {{ .Values.tag }}\",\n    \"tmpl\": \"{{a}}-{{b\"\n}\n"), 0644)
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, want) {
+		t.Fatalf("GetState() == %v, %v, want %v", state, err, want)
+	}
+
+	// and with a checksum, in a versioned envelope
+	if err := store.WriteState(want); err != nil {
+		t.Fatal(err)
+	}
+
+	// {{ is written as is, since state files are read without injections
+	if dat, _ := ioutil.ReadFile(filename); !bytes.Contains(dat, []byte(`"echo {{ .Values.tag }}"`)) {
+		t.Errorf("Expected {{ to be written unescaped, got %s", dat)
+	}
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, want) {
+		t.Errorf("GetState() == %v, %v, want %v", state, err, want)
+	}
+}
+
+func TestStateFloats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+
+	want := v.Object{
+		v.String("cpu"):      v.Float(2),
+		v.String("replicas"): v.Integer(2),
+		v.String("limits"):   v.Array{v.Float(0), v.Float(-1), v.Float(0.5)},
+	}
+
+	if err := store.WriteState(want); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, want) {
+		t.Errorf("GetState() == %v, %v, want %v", state, err, want)
+	}
+}
+
+func TestStateMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	// a state file written before state files were versioned
+	ioutil.WriteFile(filename, []byte(`{"version": 3, "hello": "there"}`), 0644)
+
+	want := v.Object{
+		v.String("version"): v.Integer(3),
+		v.String("hello"):   v.String("there"),
+	}
+
+	state, err := store.GetState()
+
+	if err != nil || !v.IsEqual(state, want) {
+		t.Fatalf("GetState() == %v, %v, want %v", state, err, want)
+	}
+
+	if err := store.WriteState(state); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, _ := store.GetStateMeta()
+
+	if meta.Version != StateFormatVersion || meta.Serial != 1 || meta.Lineage == "" {
+		t.Errorf("Failed on migrated write, %+v", meta)
+	}
+
+	// a state file with a checksum written before state files were versioned
+	sum, _ := StateHash(want)
+	env := `{"checksum": "` + sum + `", "state": {"version": 3, "hello": "there"} }`
+	ioutil.WriteFile(filename, []byte(env), 0644)
+
+	if state, err := store.GetState(); err != nil || !v.IsEqual(state, want) {
+		t.Errorf("GetState() == %v, %v, want %v", state, err, want)
+	}
+
+	ioutil.WriteFile(filename, []byte(strings.Replace(env, "there", "here", 1)), 0644)
+
+	if _, err := store.GetState(); !errors.Is(err, ErrCorruptState) {
+		t.Errorf("GetState() returned %v for an edited state file, want ErrCorruptState", err)
+	}
+
+	ioutil.WriteFile(filename, []byte(env), 0644)
+	store.WriteState(want)
+
+	// state files written by a newer version of Porter are refused
+	dat, _ := ioutil.ReadFile(filename)
+	dat = []byte(strings.Replace(string(dat), `"version": 1`, `"version": 99`, 1))
+	ioutil.WriteFile(filename, dat, 0644)
+
+	if _, err := store.GetState(); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("GetState() returned %v for a newer state file", err)
+	}
+}
+
+func TestStateStaleSerial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val1 := v.Object{v.String("hello"): v.String("there")}
+	val2 := v.Object{v.String("general"): v.String("kenobi")}
+
+	store1, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	store2, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+
+	store1.WriteState(val1)
+
+	store1.GetState()
+	store2.GetState()
+
+	if err := store2.WriteState(val2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store1.WriteState(val1); !errors.Is(err, ErrStaleSerial) {
+		t.Errorf("WriteState() returned %v for a stale state, want ErrStaleSerial", err)
+	}
+
+	// checkpoints of a stale state are refused as well
+	if err := store1.WriteCheckpoint(val1); !errors.Is(err, ErrStaleSerial) {
+		t.Errorf("WriteCheckpoint() returned %v for a stale state, want ErrStaleSerial", err)
+	}
+
+	// the state is written once it is read again
+	store1.GetState()
+
+	if err := store1.WriteState(val1); err != nil {
+		t.Errorf("WriteState() returned %v after reading the state", err)
+	}
+
+	meta, _ := store1.GetStateMeta()
+
+	if meta.Serial != 3 {
+		t.Errorf("Serial is %d, want 3", meta.Serial)
+	}
+}
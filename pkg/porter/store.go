@@ -1,34 +1,48 @@
 package porter
 
-// TODO -- place a lock on the file for safety -- all Stores should implement
-// the locking interface somehow. This needs some work and guidance from similarly
-// motivated locking mechanisms (Terraform, .kube/config, etc)
-
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"io"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"time"
 
 	"github.com/porterdev/ego/pkg/json"
 )
 
-// Store implements methods to read and write from a state store. See
-// LocalStore for an implementation.
+// stateEncoding is the JSON encoding used for state and backup files
+var stateEncoding = json.EncoderOptions{
+	SortKeys: true,
+	Indent:   "  ",
+}
+
+// ErrCorruptState is returned by LocalStore when a state file cannot be decoded
+// or does not match its checksum
+var ErrCorruptState = errors.New("State file does not match its checksum, it may be truncated or corrupted")
+
+// Store implements methods to read and write from a state store, and to lock
+// the state. See LocalStore for an implementation.
 type Store interface {
+	Locker
+
 	GetState() (Object, error)
-	GetAllBackups() ([]string, error)
-	GetBackup(filename string) (Object, error)
+	GetAllBackups() ([]*Backup, error)
+	GetBackup(id string) (Object, error)
 	WriteState(v Object) error
+	WriteCheckpoint(v Object) error
 	WriteBackup(filename string) error
+	RestoreBackup(id string) error
 }
 
 // LocalStore is an implementation of a store that uses the local filesystem
-// to store state and backups.
+// to store state and backups. The state is locked with a lock file in StateDir.
+//
+// The state is stored in an envelope with its StateMeta. LocalStore refuses to
+// write the state if another process wrote it since the store last read or
+// wrote it, see ErrStaleSerial.
 type LocalStore struct {
 	ID string
 
@@ -37,7 +51,19 @@ type LocalStore struct {
 	StateDir  string
 	BackupDir string
 
-	Lock Lock
+	// Retention limits the backups that are kept when a backup is written. The
+	// zero value keeps every backup.
+	Retention BackupRetention
+
+	// the lock file, while the state is locked by this store
+	lock *os.File
+
+	// a checkpoint has been written since the last call to WriteState
+	checkpointed bool
+
+	// the metadata of the state when the store last read or wrote it, or an
+	// empty StateMeta if there was no state
+	seen *StateMeta
 }
 
 // NewLocalStore initializes a local store
@@ -56,114 +82,166 @@ func NewLocalStore(id string, logger *Logger, stateDir, backupDir string) (*Loca
 		Logger:    logger,
 		StateDir:  stateDir,
 		BackupDir: backupDir,
+		Retention: DefaultBackupRetention,
 	}, nil
 }
 
-// GetState returns the current state as a Porter object.
+// stateFile returns the path of the state file
+func (s *LocalStore) stateFile() string {
+	return filepath.Join(s.StateDir, "state_"+s.ID+".json")
+}
+
+// GetState returns the current state as a Porter object. If no state has been
+// written yet, the state is nil. The state is verified against its checksum,
+// and ErrCorruptState is returned if it does not match, e.g. because the file
+// was truncated.
 func (s *LocalStore) GetState() (Object, error) {
-	filename := filepath.Join(s.StateDir, "state_"+s.ID+".json")
+	state, meta, err := s.readState()
 
-	dat, err := ioutil.ReadFile(filename)
-	s.Logger.Check(err, s.ID, "error reading state file", filename)
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := json.Inject(string(dat))
-	s.Logger.Check(err, s.ID, "error converting state file to json", filename)
+	s.seen = meta
 
-	return res, nil
+	return state, nil
 }
 
-// GetAllBackups returns an array of filenames, sorted from most recent to
-// least recent, of all backup timestamps in a directory and all subdirectories.
-//
-// We query subdirectories so that backups stored in folders such as /archive and
-// /backups can be added. This places some constraints on the recommended folder
-// organization.
-func (s *LocalStore) GetAllBackups() ([]string, error) {
-	// add complete paths when encountered
-	files := []string{}
-	pattern := "backup_" + s.ID + `_\d*\.json`
-
-	fileinfos, err := ioutil.ReadDir(s.BackupDir)
-	s.Logger.Check(err, s.ID, "error reading directory", s.BackupDir)
-
-	for _, fi := range fileinfos {
-		fn := fi.Name()
-		match, err := regexp.Match(pattern, []byte(fn))
-
-		if err == nil && match && !fi.IsDir() {
-			files = append(files, fn)
-		}
-	}
+// GetStateMeta returns the metadata of the current state, or nil if no state
+// has been written yet.
+func (s *LocalStore) GetStateMeta() (*StateMeta, error) {
+	_, meta, err := s.readState()
 
-	// TODO -- SORT THE BACKUPS
+	if err != nil || meta.Version == 0 {
+		return nil, err
+	}
 
-	return files, nil
+	return meta, nil
 }
 
-// GetBackup returns the a backup based on a timestamp in the form of a
-// string.
-func (s *LocalStore) GetBackup(filename string) (Object, error) {
+// readState reads and decodes the state file. If there is no state file, the
+// state is nil and the metadata is empty.
+func (s *LocalStore) readState() (Object, *StateMeta, error) {
+	filename := s.stateFile()
+
+	if !FileExists(filename) {
+		return nil, &StateMeta{}, nil
+	}
+
 	dat, err := ioutil.ReadFile(filename)
-	s.Logger.Check(err, s.ID, "error reading backup file", filename)
 
-	res, err := json.Inject(string(dat))
-	s.Logger.Check(err, s.ID, "error converting backup file to json", filename)
+	if err != nil {
+		return nil, nil, &StoreError{ID: s.ID, Op: "read state", Path: filename, Err: err}
+	}
+
+	state, meta, err := decodeState(dat)
 
-	return res, nil
+	if err != nil {
+		return nil, nil, &StoreError{ID: s.ID, Op: "decode state", Path: filename, Err: err}
+	}
+
+	return state, meta, nil
 }
 
-// WriteState saves a Porter object to the filesystem as JSON.
+// WriteState saves a Porter object to the filesystem as JSON. Object keys are
+// sorted and indented, so that state files can be diffed between writes. The
+// existing state file is stored as a backup, unless a checkpoint has been
+// written since the last call to WriteState, in which case the state before the
+// first checkpoint has already been stored as a backup.
 func (s *LocalStore) WriteState(v Object) error {
-	// convert value to JSON string
-	str, err := json.ToJSON(v)
+	backup := !s.checkpointed
+	s.checkpointed = false
 
-	s.Logger.Check(err, s.ID, "error converting to JSON")
+	return s.writeState(v, backup)
+}
 
-	// store in file
-	filename := filepath.Join(s.StateDir, "state_"+s.ID+".json")
+// WriteCheckpoint saves the state of a configuration that is partially applied,
+// so that the next run starts from the actual state if Apply does not complete.
+// Only the first checkpoint after a call to WriteState stores the existing state
+// file as a backup, so that applying a plan results in a single backup of the
+// state before the plan, however many checkpoints were written.
+func (s *LocalStore) WriteCheckpoint(v Object) error {
+	backup := !s.checkpointed
+	s.checkpointed = true
 
-	// check if file already exists -- if it does, store as backup
-	exists := FileExists(filename)
+	return s.writeState(v, backup)
+}
 
-	if exists {
-		err = s.WriteBackup(filename)
-		s.Logger.Check(err, s.ID, "error writing backup file")
+// writeState saves a Porter object to the state file, and stores the existing
+// state file as a backup if backup is true. The state file is replaced
+// atomically, so that a crash leaves either the previous or the new state.
+//
+// The serial of the state is incremented, and the lineage of the existing state
+// is kept. If the existing state is not the state that the store last read or
+// wrote, ErrStaleSerial is returned.
+func (s *LocalStore) writeState(v Object, backup bool) error {
+	_, current, err := s.readState()
+
+	if err != nil {
+		return err
 	}
 
-	err = ioutil.WriteFile(filename, []byte(str), 0644)
-	s.Logger.Check(err, s.ID, "error saving state file")
+	if s.seen != nil && (current.Lineage != s.seen.Lineage || current.Serial != s.seen.Serial) {
+		return &StoreError{
+			ID:   s.ID,
+			Op:   "write state",
+			Path: s.stateFile(),
+			Err:  fmt.Errorf("%w: serial %d, expected %d", ErrStaleSerial, current.Serial, s.seen.Serial),
+		}
+	}
 
-	return nil
+	return s.replaceState(v, current, backup)
 }
 
-// WriteBackup takes in a state file and writes a backup, using the ID
-// stored in the LocalStore object. It then removes the existing state
-// file.
-func (s *LocalStore) WriteBackup(filename string) error {
-	src, err := os.Open(filename)
-	s.Logger.Check(err, s.ID, "error reading file")
-	defer src.Close()
+// replaceState saves a Porter object to the state file as the state following
+// the state described by current, without reading the existing state file.
+func (s *LocalStore) replaceState(v Object, current *StateMeta, backup bool) error {
+	filename := s.stateFile()
+
+	meta := &StateMeta{
+		Version:          StateFormatVersion,
+		Serial:           current.Serial + 1,
+		Lineage:          current.Lineage,
+		FrameworkVersion: FrameworkVersion,
+		Timestamp:        time.Now().UTC(),
+	}
 
-	dir := filepath.Dir(filename)
+	if meta.Lineage == "" {
+		meta.Lineage = newLineage()
+	}
 
-	// if s.StateDir is not same directory as filename, throw an error
-	if rel, err := filepath.Rel(dir, s.StateDir); err != nil || rel != "." {
-		return errors.New("LocalStore Path does not match filename directory")
+	var err error
+
+	if meta.Checksum, err = StateHash(v); err != nil {
+		return &StoreError{ID: s.ID, Op: "encode state", Path: filename, Err: err}
 	}
 
-	ts := strconv.Itoa(int(time.Now().Unix()))
-	backup := filepath.Join(s.BackupDir, "backup_"+s.ID+"_"+ts+".json")
+	dat, err := encodeState(v, meta)
+
+	if err != nil {
+		return &StoreError{ID: s.ID, Op: "encode state", Path: filename, Err: err}
+	}
 
-	dest, err := os.OpenFile(backup, os.O_RDWR|os.O_CREATE, 0666)
-	s.Logger.Check(err, s.ID, "error opening backup file")
-	defer dest.Close()
+	// check if file already exists -- if it does, store as backup
+	if backup && FileExists(filename) {
+		if err := s.WriteBackup(filename); err != nil {
+			return err
+		}
+	}
 
-	_, err = io.Copy(dest, src)
-	s.Logger.Check(err, s.ID, "error copying from state file to backup file")
+	if err := writeFileAtomic(filename, dat); err != nil {
+		return &StoreError{ID: s.ID, Op: "write state", Path: filename, Err: err}
+	}
 
-	// if we've reached here, remove state file
-	err = os.Remove(filename)
-	s.Logger.Check(err, s.ID, "error removing existing state file")
+	s.seen = meta
 
 	return nil
 }
+
+// checksum returns the SHA-256 checksum of the contents of a file, e.g.
+// "sha256:2c26b4..."
+func checksum(dat []byte) string {
+	sum := sha256.Sum256(dat)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -1,9 +1,11 @@
 package porter
 
 import (
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/porterdev/ego/pkg/json"
@@ -12,6 +14,14 @@ import (
 )
 
 func TestStoreSingleWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
 	logger := NewLogger(2)
 
 	val := v.Object{
@@ -19,14 +29,13 @@ func TestStoreSingleWrite(t *testing.T) {
 		v.String("general"): v.String("kenobi"),
 	}
 
-	store, _ := NewLocalStore("12345", logger, "./", "./")
+	store, _ := NewLocalStore("12345", logger, dir, dir)
 
 	store.WriteState(val)
-	defer os.RemoveAll("./state_12345.json")
 
 	// read the contents of the file and convert to object
-	dat, _ := ioutil.ReadFile("./state_12345.json")
-	res, _ := json.Inject(string(dat))
+	dat, _ := ioutil.ReadFile(filepath.Join(dir, "state_12345.json"))
+	res, _, _ := decodeState(dat)
 
 	if !v.IsEqual(res, val) {
 		res1, _ := json.ToJSON(res)
@@ -36,7 +45,13 @@ func TestStoreSingleWrite(t *testing.T) {
 }
 
 func TestStoreMultiWrite(t *testing.T) {
-	logger := NewLogger(2)
+	dir, err := ioutil.TempDir("", "porter-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
 
 	val1 := v.Object{
 		v.String("hello"):   v.String("there"),
@@ -48,52 +63,154 @@ func TestStoreMultiWrite(t *testing.T) {
 		v.String("general2"): v.String("kenobi2"),
 	}
 
-	store, _ := NewLocalStore("12345", logger, "./", "./")
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+
+	store.WriteState(val1)
+	store.WriteState(val2)
+
+	// the first state is stored as a backup when the second is written
+	backups, err := store.GetAllBackups()
+
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v, %v", backups, err)
+	}
+
+	backup, err := store.GetBackup(backups[0].ID)
+
+	if err != nil || !v.IsEqual(backup, val1) {
+		t.Errorf("Failed on backup, %v, %v, %v", backup, val1, err)
+	}
+
+	state, err := store.GetState()
+
+	if err != nil || !v.IsEqual(state, val2) {
+		t.Errorf("Failed on state, %v, %v, %v", state, val2, err)
+	}
+}
+
+func TestStoreStableWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val := v.Object{
+		v.String("spec"): v.Object{
+			v.String("replicas"): v.Integer(3),
+			v.String("image"):    v.String("nginx"),
+		},
+		v.String("kind"): v.String("Deployment"),
+	}
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	want := `  "state": {
+    "kind": "Deployment",
+    "spec": {
+      "image": "nginx",
+      "replicas": 3
+    }
+  },
+`
+
+	for i := 0; i < 5; i++ {
+		store.WriteState(val)
+
+		dat, _ := ioutil.ReadFile(filename)
+
+		if !strings.Contains(string(dat), want) {
+			t.Fatalf("Failed on stable write %d: %s, %s", i, dat, want)
+		}
+	}
+}
+
+func TestStoreBackupCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val1 := v.Object{v.String("version"): v.Integer(1)}
+	val2 := v.Object{v.String("version"): v.Integer(2)}
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
 
 	store.WriteState(val1)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	// the state file is not removed while the backup is written
+	if err := store.WriteBackup(filename); err != nil || !FileExists(filename) {
+		t.Fatalf("WriteBackup() removed the state file, %v", err)
+	}
+
 	store.WriteState(val2)
-	defer os.RemoveAll("./state_12345.json")
 
-	// read the backups
+	// backups written in the same second do not overwrite each other
 	backups, _ := store.GetAllBackups()
 
-	fmt.Println(backups)
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
 
-	// store.WriteState(val2)
-	// defer os.RemoveAll("./state_12345.json")
+	for _, b := range backups {
+		backup, _ := store.GetBackup(b.ID)
 
-	// read the contents of the file and convert to object
-	// dat, _ := ioutil.ReadFile("./state_12345.json")
-	// res, _ := json.Inject(string(dat))
-
-	// if !v.IsEqual(res, val) {
-	// 	res1, _ := json.ToJSON(res)
-	// 	res2, _ := json.ToJSON(val)
-	// 	t.Errorf("Failed on simple write, %s, %s", res1, res2)
-	// }
+		if !v.IsEqual(backup, val1) {
+			t.Errorf("Backup %s is %v, want %v", b.ID, backup, val1)
+		}
+	}
+
+	// no temporary files are left behind
+	files, _ := ioutil.ReadDir(dir)
+
+	for _, fi := range files {
+		if strings.Contains(fi.Name(), ".tmp-") {
+			t.Errorf("Temporary file %s was not removed", fi.Name())
+		}
+	}
 }
 
-// func TestStoreMultipleWrite(t *testing.T) {
-// 	for _, c := range writeSingleTests {
-// 		logger := NewLogger(2)
-
-// 		store := LocalStore{
-// 			ID:     c.id,
-// 			Path:   c.dirpath,
-// 			Logger: logger,
-// 		}
-
-// 		store.WriteState(c.value)
-// 		defer os.RemoveAll(c.want.filepath)
-
-// 		// read the contents of the file and convert to object
-// 		dat, _ := ioutil.ReadFile(c.want.filepath)
-// 		res, _ := json.Inject(string(dat))
-
-// 		if !v.IsEqual(res, c.want.res) {
-// 			res1, _ := json.ToJSON(res)
-// 			res2, _ := json.ToJSON(c.want.res)
-// 			t.Errorf("Failed on: %s, %s, %s", c.name, res1, res2)
-// 		}
-// 	}
-// }
+func TestStoreChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porter-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	val1 := v.Object{v.String("version"): v.Integer(1)}
+	val2 := v.Object{v.String("version"): v.Integer(2)}
+
+	store, _ := NewLocalStore("12345", NewLogger(0), dir, dir)
+	filename := filepath.Join(dir, "state_12345.json")
+
+	store.WriteState(val1)
+	dat, _ := ioutil.ReadFile(filename)
+
+	// a state that was edited does not match
+	edited := strings.Replace(string(dat), `    "version": 1`, `    "version": 2`, 1)
+	ioutil.WriteFile(filename, []byte(edited), 0644)
+
+	if _, err := store.GetState(); !errors.Is(err, ErrCorruptState) {
+		t.Errorf("GetState() returned %v for an edited state file, want ErrCorruptState", err)
+	}
+
+	ioutil.WriteFile(filename, dat, 0644)
+	store.WriteState(val2)
+	dat, _ = ioutil.ReadFile(filename)
+
+	// a truncated state file does not match
+	ioutil.WriteFile(filename, dat[:len(dat)/2], 0644)
+
+	if _, err := store.GetState(); !errors.Is(err, ErrCorruptState) {
+		t.Errorf("GetState() returned %v for a truncated state file, want ErrCorruptState", err)
+	}
+}
@@ -0,0 +1,207 @@
+package toml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+
+	"github.com/porterdev/ego/internal/inject"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// Bindings maps the code inside each injection {{}} to the value injected in
+// its place, see inject.Bindings
+type Bindings = inject.Bindings
+
+// Parse creates a Porter Value from a TOML document. The document has no
+// injections, so text that looks like a placeholder is kept as is.
+func Parse(src []byte) (v.Value, error) {
+	tree, err := toml.Load(string(src))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toValue(tree.ToMap(), nil)
+}
+
+// InjectNamed takes in a TOML document that contains Porter injection syntax {{}}
+// and creates a Porter Value, binding each injection to a value by its name. It
+// is an error for an injection to have no binding, or for a binding to be unused.
+func InjectNamed(src string, b Bindings) (v.Value, error) {
+	return inject.Named(b, func(resolve func(name string) (v.Value, error)) (v.Value, error) {
+		return InjectFunc(src, resolve)
+	})
+}
+
+// InjectFunc takes in a TOML document that contains Porter injection syntax {{}}
+// and creates a Porter Value, calling resolve with the name of each injection
+// to get its value.
+//
+// An injection that makes up a whole value or key is replaced by its value. An
+// injection inside of a string, or that makes up a whole string, is converted
+// to a string and concatenated with the rest of the string, as in YAML.
+func InjectFunc(src string, resolve func(name string) (v.Value, error)) (v.Value, error) {
+	doc, injections, err := replaceInjections(src, resolve)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := toml.Load(doc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return toValue(tree.ToMap(), injections)
+}
+
+// replaceInjections replaces each injection in src with a placeholder, and
+// resolves the value of each injection in order of appearance. Outside of a
+// string, the placeholder is quoted so that it is a valid TOML value or key.
+func replaceInjections(src string, resolve func(name string) (v.Value, error)) (string, []inject.Injection, error) {
+	if err := inject.CheckReserved(src); err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var injections []inject.Injection
+
+	// delim is the delimiter of the string or comment at offs, if any
+	delim := ""
+	offs := 0
+
+	for offs < len(src) {
+		if strings.HasPrefix(src[offs:], "{{") {
+			end := strings.Index(src[offs+2:], "}}")
+
+			if end < 0 {
+				return "", nil, inject.ErrorAt(src, len(src), "No closing injection }} found")
+			}
+
+			end += offs + 2
+
+			name := strings.TrimSpace(src[offs+2 : end])
+			val, err := resolve(name)
+
+			if err != nil {
+				return "", nil, inject.ErrorAt(src, offs, err.Error())
+			}
+
+			p := inject.Placeholder(len(injections))
+
+			if delim == "" {
+				p = `"` + p + `"`
+			}
+
+			b.WriteString(p)
+
+			injections = append(injections, inject.Injection{
+				Name:   name,
+				Val:    val,
+				Quoted: delim != "" && delim != "#",
+			})
+
+			offs = end + 2
+			continue
+		}
+
+		n := 1
+
+		switch {
+		case delim == "#":
+			if src[offs] == '\n' {
+				delim = ""
+			}
+		case delim == `"` || delim == `"""`:
+			if src[offs] == '\\' && offs+1 < len(src) {
+				n = 2
+			} else if strings.HasPrefix(src[offs:], delim) {
+				n, delim = len(delim), ""
+			}
+		case delim != "":
+			if strings.HasPrefix(src[offs:], delim) {
+				n, delim = len(delim), ""
+			}
+		case src[offs] == '#':
+			delim = "#"
+		case strings.HasPrefix(src[offs:], `"""`), strings.HasPrefix(src[offs:], "'''"):
+			n, delim = 3, src[offs:offs+3]
+		case src[offs] == '"' || src[offs] == '\'':
+			delim = src[offs : offs+1]
+		}
+
+		b.WriteString(src[offs : offs+n])
+		offs += n
+	}
+
+	return b.String(), injections, nil
+}
+
+// toValue converts a value decoded by the toml package to a Porter Value,
+// replacing placeholders with the injected values
+func toValue(node interface{}, injections []inject.Injection) (v.Value, error) {
+	switch n := node.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return v.Boolean(n), nil
+	case int64:
+		return v.Integer(n), nil
+	case uint64:
+		return v.Float(n), nil
+	case float64:
+		return v.Float(n), nil
+	case time.Time:
+		return v.String(n.Format(time.RFC3339Nano)), nil
+	case string:
+		return inject.Substitute(n, injections)
+	case *toml.Tree:
+		return toValue(n.ToMap(), injections)
+	case []interface{}:
+		arr := make(v.Array, 0, len(n))
+
+		for _, elem := range n {
+			val, err := toValue(elem, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, val)
+		}
+
+		return arr, nil
+	case map[string]interface{}:
+		obj := make(v.Object, len(n))
+
+		for k, elem := range n {
+			key, err := inject.Substitute(k, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			str, err := v.Stringify(key)
+
+			if err != nil {
+				return nil, fmt.Errorf("Keys must be a string, number, boolean or null")
+			}
+
+			val, err := toValue(elem, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj[v.String(str)] = val
+		}
+
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported TOML type %T", node)
+}
@@ -0,0 +1,188 @@
+package toml
+
+import (
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type parseTest struct {
+	name string
+	toml string
+	want v.Value
+}
+
+var parseTestsPass = []parseTest{
+	parseTest{
+		name: "Parse: scalars",
+		toml: "a = 1\nb = 1.5\nc = true\nd = \"hello\"\ne = 'raw'\nf = 1979-05-27T07:32:00Z\n",
+		want: v.Object{
+			"a": v.Integer(1),
+			"b": v.Float(1.5),
+			"c": v.Boolean(true),
+			"d": v.String("hello"),
+			"e": v.String("raw"),
+			"f": v.String("1979-05-27T07:32:00Z"),
+		},
+	},
+	parseTest{
+		name: "Parse: tables",
+		toml: `title = "web"
+
+[server]
+ports = [80, 443]
+labels = { app = "web" }
+
+[[containers]]
+name = "nginx"
+
+[[containers]]
+name = "redis"
+`,
+		want: v.Object{
+			"title": v.String("web"),
+			"server": v.Object{
+				"ports": v.Array{
+					v.Integer(80),
+					v.Integer(443),
+				},
+				"labels": v.Object{
+					"app": v.String("web"),
+				},
+			},
+			"containers": v.Array{
+				v.Object{
+					"name": v.String("nginx"),
+				},
+				v.Object{
+					"name": v.String("redis"),
+				},
+			},
+		},
+	},
+	parseTest{
+		name: "Parse: placeholder text",
+		toml: "a = \"__porter_inject_3__\"\n",
+		want: v.Object{
+			"a": v.String("__porter_inject_3__"),
+		},
+	},
+}
+
+func TestParsePass(t *testing.T) {
+	for _, c := range parseTestsPass {
+		res, err := Parse([]byte(c.toml))
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+type injectNamedTest struct {
+	name     string
+	toml     string
+	bindings Bindings
+	want     v.Value
+	msg      string
+}
+
+var injectNamedTestsPass = []injectNamedTest{
+	injectNamedTest{
+		name: "InjectNamed: whole values",
+		toml: "replicas = {{ replicas }}\nlabels = {{labels}}\nports = [{{port}}, {{port}}]\n",
+		bindings: Bindings{
+			"replicas": v.Integer(2),
+			"labels": v.Object{
+				"app": v.String("web"),
+			},
+			"port": v.Integer(80),
+		},
+		want: v.Object{
+			"replicas": v.Integer(2),
+			"labels": v.Object{
+				"app": v.String("web"),
+			},
+			"ports": v.Array{
+				v.Integer(80),
+				v.Integer(80),
+			},
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: strings",
+		toml: "image = \"nginx:{{tag}}\"\n\"{{app}}-svc\" = '{{app}}:{{port}}'\nport = \"{{port}}\"\ncmd = \"\"\"\nrun \\\"{{app}}\\\"\n\"\"\"\nraw = '''{{port}}'''\n",
+		bindings: Bindings{
+			"tag":  v.String("1.19"),
+			"app":  v.String("web"),
+			"port": v.Integer(80),
+		},
+		want: v.Object{
+			"image":   v.String("nginx:1.19"),
+			"web-svc": v.String("web:80"),
+			"port":    v.String("80"),
+			"cmd":     v.String("run \"web\"\n"),
+			"raw":     v.String("80"),
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: tables and comments",
+		toml: "# the {{key}} table\n[{{key}}]\n{{key}} = [{{val}}] # {{val}}\n",
+		bindings: Bindings{
+			"key": v.String("foo"),
+			"val": v.String("bar"),
+		},
+		want: v.Object{
+			"foo": v.Object{
+				"foo": v.Array{
+					v.String("bar"),
+				},
+			},
+		},
+	},
+}
+
+func TestInjectNamedPass(t *testing.T) {
+	for _, c := range injectNamedTestsPass {
+		res, err := InjectNamed(c.toml, c.bindings)
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+var injectNamedTestsFail = []injectNamedTest{
+	injectNamedTest{
+		name:     "InjectNamed: unknown name",
+		toml:     "a = \"}}\"\nb = {{foo}}\n",
+		bindings: Bindings{},
+		msg:      "Column 5, Line 2: No value bound to injection foo",
+	},
+	injectNamedTest{
+		name: "InjectNamed: no closing injection",
+		toml: "a = {{foo\n",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+		},
+		msg: "Column 1, Line 2: No closing injection }} found",
+	},
+	injectNamedTest{
+		name: "InjectNamed: quoted object",
+		toml: "a = \"{{foo}}\"\n",
+		bindings: Bindings{
+			"foo": v.Object{},
+		},
+		msg: "Injection foo inside a string must be a string, number, boolean or null",
+	},
+}
+
+func TestInjectNamedFail(t *testing.T) {
+	for _, c := range injectNamedTestsFail {
+		_, err := InjectNamed(c.toml, c.bindings)
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.toml, c.msg, err)
+		}
+	}
+}
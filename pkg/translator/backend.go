@@ -0,0 +1,126 @@
+package translator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language of HEREDOCs whose name does not select a
+// registered backend, e.g. <<test
+const DefaultLanguage = "json"
+
+// Backend translates a HEREDOC to a Go expression that generates its value.
+type Backend func(h Heredoc) string
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+func init() {
+	Register("json", InjectBackend("json"))
+	Register("yaml", InjectBackend("yaml"))
+	Register("toml", InjectBackend("toml"))
+	Register("text", TextBackend)
+}
+
+// Register makes a backend available for HEREDOCs of a language, so that a
+// HEREDOC named <<lang or <<file.lang is translated by the backend. Register
+// panics if the backend is nil or if a backend is already registered for the
+// language.
+func Register(lang string, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	lang = strings.ToLower(lang)
+
+	if b == nil {
+		panic("translator: Register backend is nil")
+	}
+
+	if _, dup := backends[lang]; dup {
+		panic("translator: Register called twice for language " + lang)
+	}
+
+	backends[lang] = b
+}
+
+// Resolve returns the language of the backend that translates a HEREDOC with
+// the given name, which is DefaultLanguage if no backend is registered for the
+// language of the HEREDOC.
+func Resolve(name string) string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	if _, ok := backends[Language(name)]; ok {
+		return Language(name)
+	}
+
+	return DefaultLanguage
+}
+
+// Lookup returns the backend that translates a HEREDOC with the given name.
+func Lookup(name string) Backend {
+	lang := Resolve(name)
+
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	return backends[lang]
+}
+
+// InjectBackend returns a backend that translates a HEREDOC to a call to
+// InjectNamed in the given package, which binds each injection by name using
+// the package's Bindings type.
+func InjectBackend(pkg string) Backend {
+	return func(h Heredoc) string {
+		var pairs []string
+		seen := make(map[string]bool)
+
+		for _, inj := range h.Injections() {
+			c := inj.Code
+
+			if len(c) > 0 && !seen[c] {
+				seen[c] = true
+				pairs = append(pairs, strconv.Quote(c)+": "+LineComment(h.File, inj.Pos)+c)
+			}
+		}
+
+		return fmt.Sprintf("%s.InjectNamed(%s, %s.Bindings{%s})", pkg, rawString(string(h.Body)), pkg, strings.Join(pairs, ", "))
+	}
+}
+
+// TextBackend translates a HEREDOC to a string, concatenating the literal
+// text with the value of each injection formatted by fmt.Sprint, so the file
+// of the HEREDOC must import fmt if it has injections.
+func TextBackend(h Heredoc) string {
+	text, _ := h.Split()
+
+	var b strings.Builder
+
+	for i, inj := range h.Injections() {
+		b.WriteString(rawString(text[i]))
+		b.WriteString(" + fmt.Sprint(")
+		b.WriteString(LineComment(h.File, inj.Pos))
+		b.WriteString(inj.Code)
+		b.WriteString(") + ")
+	}
+
+	b.WriteString(rawString(text[len(text)-1]))
+
+	return b.String()
+}
+
+// rawString returns a Go raw string literal for str. Backticks, which cannot
+// appear in a raw string, are concatenated as interpreted string literals.
+func rawString(str string) string {
+	parts := strings.Split(str, "`")
+
+	for i, part := range parts {
+		parts[i] = "`" + part + "`"
+	}
+
+	return strings.Join(parts, " + \"`\" + ")
+}
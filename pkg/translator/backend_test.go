@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{in: "deployment.yaml", want: "yaml"},
+		{in: "deployment.YML", want: "yaml"},
+		{in: "json", want: "json"},
+		{in: "config.toml", want: "toml"},
+		{in: "notes.txt", want: "text"},
+		{in: "text", want: "text"},
+		{in: "test", want: DefaultLanguage},
+		{in: "config.unknown", want: DefaultLanguage},
+	}
+
+	for _, c := range cases {
+		got := Resolve(c.in)
+
+		if got != c.want {
+			t.Errorf("Resolve(%q) == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+var testMixed = []transTest{
+	{
+		in:  "a := <<a.json\n{{x}}\na.json>>\nb := <<b.yaml\nk: {{ y }}\nb.yaml>>\nc := <<c.txt\nhi {{name}}!\nc.txt>>",
+		out: "a := json.InjectNamed(`\n{{x}}\n`, json.Bindings{\"x\": x})\nb := yaml.InjectNamed(`\nk: {{ y }}\n`, yaml.Bindings{\"y\": y})\nc := `\nhi ` + fmt.Sprint(name) + `!\n`",
+	},
+	{
+		in:  "d := <<d.toml\nport = {{ port }}\nd.toml>>",
+		out: "d := toml.InjectNamed(`\nport = {{ port }}\n`, toml.Bindings{\"port\": port})",
+	},
+	{
+		in:  "<<text\nrun `ls`\ntext>>",
+		out: "`\nrun ` + \"`\" + `ls` + \"`\" + `\n`",
+	},
+	{
+		in:  "<<lower.upper\n{{x}}\nlower.upper>>",
+		out: "upper(`\n{{x}}\n`)",
+	},
+}
+
+func TestTranslateMixed(t *testing.T) {
+	Register("UPPER", func(h Heredoc) string {
+		return "upper(" + rawString(string(h.Body)) + ")"
+	})
+
+	for _, c := range testMixed {
+		trans := NewTranslator([]byte(c.in))
+
+		res := trans.Translate()
+
+		if string(res) != c.out {
+			t.Errorf("(%s).Translate() expected %s, got %s", c.in, c.out, res)
+		}
+	}
+}
+
+func TestTextBackendTypes(t *testing.T) {
+	trans := NewTranslator([]byte("package p\n\nimport \"fmt\"\n\nvar replicas, ready = 3, true\n\nvar s string = <<text\nreplicas: {{replicas}}, ready: {{ ready }}\ntext>>\n"))
+	res := trans.Translate()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", res, 0)
+
+	if err != nil {
+		t.Fatalf("Translate() returned invalid Go: %v\n%s", err, res)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+
+	if _, err := conf.Check("p", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("Translate() of non-string injections does not type check: %v\n%s", err, res)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register(\"json\") expected panic for duplicate language")
+		}
+	}()
+
+	Register("json", TextBackend)
+}
+
+func TestSplit(t *testing.T) {
+	h := Heredoc{Body: []byte("a{{b}}{{ c }}d{{e")}
+
+	text, code := h.Split()
+
+	if len(text) != 3 || text[0] != "a" || text[1] != "" || text[2] != "d{{e" ||
+		len(code) != 2 || code[0] != "b" || code[1] != " c " {
+		t.Errorf("(%s).Split() got %q, %q", h.Body, text, code)
+	}
+}
@@ -0,0 +1,118 @@
+package translator
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// SourceMap maps positions in a translated file back to positions in the
+// source file that it was translated from.
+type SourceMap struct {
+	File string // name of the source file
+
+	src      []byte
+	lines    []int // offset of the start of each line of the translation
+	segments []segment
+}
+
+// segment is a span of the translation that starts at offset gen. A verbatim
+// span is copied from the source starting at offset src, while every offset
+// of any other span maps to the source offset src.
+type segment struct {
+	gen, src int
+	verbatim bool
+}
+
+// add records that the translation continues at offset gen with a span that
+// maps to the source offset src
+func (m *SourceMap) add(gen, src int, verbatim bool) {
+	m.segments = append(m.segments, segment{
+		gen:      gen,
+		src:      src,
+		verbatim: verbatim,
+	})
+}
+
+// setTranslation records the offset of each line in the translation
+func (m *SourceMap) setTranslation(res []byte) {
+	m.lines = []int{0}
+
+	for i, b := range res {
+		if b == '\n' {
+			m.lines = append(m.lines, i+1)
+		}
+	}
+}
+
+// Position returns the position in the source file of a line and column in
+// the translation, where both start at 1. It returns false if the line and
+// column are not in the translation.
+func (m *SourceMap) Position(line, col int) (Position, bool) {
+	if line < 1 || line > len(m.lines) || col < 1 || len(m.segments) == 0 {
+		return Position{}, false
+	}
+
+	gen := m.lines[line-1] + col - 1
+
+	// find the last segment that starts at or before gen
+	i := sort.Search(len(m.segments), func(i int) bool {
+		return m.segments[i].gen > gen
+	}) - 1
+
+	if i < 0 {
+		return Position{}, false
+	}
+
+	seg := m.segments[i]
+	offs := seg.src
+
+	if seg.verbatim {
+		offs += gen - seg.gen
+	}
+
+	if offs > len(m.src) {
+		offs = len(m.src)
+	}
+
+	return position(m.src, offs), true
+}
+
+// Rewrite replaces each position of the form file:line or file:line:column in
+// text, where the path of file ends with the path of the translated file, with
+// the corresponding position in the source file. translated is usually relative
+// to the directory that text was produced in, e.g. the output of go build.
+func (m *SourceMap) Rewrite(translated string, text string) string {
+	re := regexp.MustCompile(`(?:[^\s:]*/)?` + regexp.QuoteMeta(filepath.ToSlash(filepath.Clean(translated))) + `:(\d+)(?::(\d+))?`)
+
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		groups := re.FindStringSubmatch(match)
+
+		line, _ := strconv.Atoi(groups[1])
+		col := 1
+
+		if groups[2] != "" {
+			col, _ = strconv.Atoi(groups[2])
+		}
+
+		pos, ok := m.Position(line, col)
+
+		if !ok {
+			return match
+		}
+
+		return fmt.Sprintf("%s:%d:%d", m.File, pos.Line, pos.Column)
+	})
+}
+
+// LineComment returns a /*line*/ directive that sets the position of the
+// character following it to pos in file, or an empty string if file is empty.
+func LineComment(file string, pos Position) string {
+	if file == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("/*line %s:%d:%d*/", file, pos.Line, pos.Column)
+}
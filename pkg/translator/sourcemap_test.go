@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"testing"
+)
+
+const sourceMapSrc = "package main\n\nfunc f() {\n\tx := <<a.json\n{ \"k\": {{ val }} }\na.json>>; y := 1\n}\n"
+
+func TestFileTranslator(t *testing.T) {
+	trans := NewFileTranslator("f.ego", []byte(sourceMapSrc))
+
+	res := trans.Translate()
+
+	want := "//line f.ego:1:1\npackage main\n\nfunc f() {\n\tx := /*line f.ego:4:7*/json.InjectNamed(`\n{ \"k\": {{ val }} }\n`, json.Bindings{\"val\": /*line f.ego:5:11*/val})/*line f.ego:6:9*/; y := 1\n}\n"
+
+	if string(res) != want {
+		t.Errorf("(%s).Translate() expected %s, got %s", sourceMapSrc, want, res)
+	}
+}
+
+type sourceMapTest struct {
+	line, col int
+	want      Position
+	ok        bool
+}
+
+var sourceMapTests = []sourceMapTest{
+	// package main
+	{line: 2, col: 9, want: Position{Offset: 8, Line: 1, Column: 9}, ok: true},
+	// x :=
+	{line: 5, col: 2, want: Position{Offset: 26, Line: 4, Column: 2}, ok: true},
+	// inside of the HEREDOC translation
+	{line: 7, col: 5, want: Position{Offset: 31, Line: 4, Column: 7}, ok: true},
+	// y := 1
+	{line: 7, col: 69, want: Position{Offset: 69, Line: 6, Column: 11}, ok: true},
+	// }
+	{line: 8, col: 1, want: Position{Offset: 76, Line: 7, Column: 1}, ok: true},
+	{line: 20, col: 1, ok: false},
+	{line: 0, col: 1, ok: false},
+}
+
+func TestSourceMapPosition(t *testing.T) {
+	trans := NewFileTranslator("f.ego", []byte(sourceMapSrc))
+	trans.Translate()
+
+	m := trans.SourceMap()
+
+	for _, c := range sourceMapTests {
+		got, ok := m.Position(c.line, c.col)
+
+		if ok != c.ok || (ok && got != c.want) {
+			t.Errorf("Position(%d, %d) expected %v, %t, got %v, %t", c.line, c.col, c.want, c.ok, got, ok)
+		}
+	}
+}
+
+func TestSourceMapRewrite(t *testing.T) {
+	trans := NewTranslator([]byte(sourceMapSrc))
+	trans.Translate()
+
+	m := trans.SourceMap()
+	m.File = "f.ego"
+
+	in := "# command-line-arguments\n./build123/build_456.go:6:32: undefined: y\n/tmp/build123/build_456.go:7 build_456.go:1:1"
+	want := "# command-line-arguments\nf.ego:6:11: undefined: y\nf.ego:7:1 build_456.go:1:1"
+
+	if got := m.Rewrite("build123/build_456.go", in); got != want {
+		t.Errorf("Rewrite(%q) expected %q, got %q", in, want, got)
+	}
+}
@@ -1,15 +1,33 @@
 package translator
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Translator implements a translator from .gop to .go files.
 type Translator struct {
 	src []byte // Source buffer
 	res []byte // resulting translation
 
+	filename  string    // name of the source file, if known
+	sourceMap SourceMap // maps the translation back to the source
+
 	scanner Scanner // scanner instance
 
 	currBlock []RichToken // the current token block
 }
 
+// Heredoc is a HEREDOC found in a source file.
+type Heredoc struct {
+	Name string   // name of the HEREDOC, e.g. "test.json" for <<test.json
+	Body []byte   // raw body of the HEREDOC, including injections
+	Pos  Position // position of the first byte of Body in the source
+	File string   // name of the source file, if known
+
+	start, end int // offsets of the opening << and the end of the closing >>
+}
+
 // NewTranslator creates a new Translator based on a source byte array
 func NewTranslator(src []byte) Translator {
 	trans := Translator{
@@ -20,6 +38,16 @@ func NewTranslator(src []byte) Translator {
 	return trans
 }
 
+// NewFileTranslator creates a new Translator for a named source file. Its
+// translations contain //line directives, so that the Go compiler reports
+// positions in the source file rather than in the translation.
+func NewFileTranslator(filename string, src []byte) Translator {
+	trans := NewTranslator(src)
+	trans.filename = filename
+
+	return trans
+}
+
 // TranslateToString takes in a HEREDOC that isn't a supported kind
 // and translates it to dynamic string using the injected variables
 func (t *Translator) TranslateToString() []byte {
@@ -57,52 +85,203 @@ func (t *Translator) TranslateToString() []byte {
 }
 
 // TranslateToJSON takes in a JSON HEREDOC and translates it to a function that
-// generates a Porter configuration using the json package.
+// generates a Porter configuration using the json package. Each injection is
+// bound to its value by the code inside the injection.
 func (t *Translator) TranslateToJSON() []byte {
+	json := InjectBackend("json")
+
+	return t.translate(func(name string) Backend {
+		return json
+	})
+}
+
+// Translate translates each HEREDOC to a Go expression using the backend
+// registered for the language of the HEREDOC, so that a source file can mix
+// HEREDOCs of different languages, e.g. <<deployment.yaml and <<config.json.
+func (t *Translator) Translate() []byte {
+	return t.translate(Lookup)
+}
+
+// translate replaces each HEREDOC with its translation by the backend returned
+// by backend for the name of the HEREDOC
+func (t *Translator) translate(backend func(name string) Backend) []byte {
 	var prevPos int = 0
-	var injections []string = make([]string, 4)
 
-	// iterate through token blocks, translating HEREDOCs into Go
-	for t.scanner.ch != eof {
-		t.currBlock = t.scanner.Scan()
+	t.sourceMap = SourceMap{
+		File: t.filename,
+		src:  t.src,
+	}
 
-		// iterate through tokens, extract code blocks
-		for _, richTok := range t.currBlock {
+	if t.filename != "" {
+		t.emit([]byte(fmt.Sprintf("//line %s:1:1\n", t.filename)), 0, false)
+	}
+
+	for _, h := range t.Heredocs() {
+		t.emit(t.src[prevPos:h.start], prevPos, true)
+		t.emit([]byte(LineComment(t.filename, position(t.src, h.start))+backend(h.Name)(h)), h.start, false)
+		t.emit([]byte(LineComment(t.filename, position(t.src, h.end))), h.end, false)
+
+		prevPos = h.end
+	}
+
+	t.emit(t.src[prevPos:], prevPos, true)
+
+	t.sourceMap.setTranslation(t.res)
+
+	return t.res
+}
+
+// emit appends part of the translation that maps to the source offset src
+func (t *Translator) emit(b []byte, src int, verbatim bool) {
+	if len(b) == 0 {
+		return
+	}
+
+	t.sourceMap.add(len(t.res), src, verbatim)
+	t.res = append(t.res, b...)
+}
+
+// SourceMap returns the source map of the last translation by Translate or
+// TranslateToJSON.
+func (t *Translator) SourceMap() *SourceMap {
+	return &t.sourceMap
+}
+
+// Language returns the language of a HEREDOC from its name, which is either the
+// language itself, e.g. <<yaml, or a file name with the language as its
+// extension, e.g. <<deployment.yaml
+func Language(name string) string {
+	lang := strings.ToLower(name)
+
+	if i := strings.LastIndex(lang, "."); i >= 0 {
+		lang = lang[i+1:]
+	}
+
+	switch lang {
+	case "yml":
+		return "yaml"
+	case "txt":
+		return "text"
+	}
+
+	return lang
+}
+
+// Heredocs returns every HEREDOC in the source, in order of appearance.
+func (t *Translator) Heredocs() []Heredoc {
+	var heredocs []Heredoc
+	var start RichToken
+
+	s := NewScanner(t.src)
+
+	for s.ch != eof {
+		for _, richTok := range s.Scan() {
 			switch richTok.tok {
 			case LHEREDOC:
-				t.res = append(t.res, t.src[prevPos:richTok.pos.Offset]...)
-				t.res = append(t.res, []byte("json.Inject(`")...)
-			case LINJECT:
-				t.res = append(t.res, t.src[prevPos:richTok.pos.Offset+len(richTok.lit)]...)
-			case CODE:
-				t.res = append(t.res, t.src[prevPos:richTok.pos.Offset+len(richTok.lit)]...)
-				injections = append(injections, richTok.lit)
-			case RINJECT:
-				t.res = append(t.res, t.src[prevPos:richTok.pos.Offset+len(richTok.lit)]...)
+				start = richTok
 			case RHEREDOC:
-				t.res = append(t.res, t.src[prevPos:richTok.pos.Offset]...)
+				offs := start.pos.Offset + len(start.lit)
 
-				// add injected variable literals to end of argument
-				terminus := "`"
+				heredocs = append(heredocs, Heredoc{
+					Name:  start.lit[2:],
+					Body:  t.src[offs:richTok.pos.Offset],
+					Pos:   position(t.src, offs),
+					File:  t.filename,
+					start: start.pos.Offset,
+					end:   richTok.pos.Offset + len(richTok.lit),
+				})
+			}
+		}
+	}
 
-				for _, c := range injections {
-					if len(c) > 0 {
-						terminus += ","
-						terminus += c
-					}
-				}
+	return heredocs
+}
 
-				terminus += ")"
+// Injection is the code of an injection in a HEREDOC.
+type Injection struct {
+	Code string   // code between {{ and }}, without surrounding whitespace
+	Pos  Position // position of the code in the source
+}
 
-				t.res = append(t.res, []byte(terminus)...)
+// Injections returns every injection in the HEREDOC, in order of appearance.
+func (h Heredoc) Injections() []Injection {
+	var injections []Injection
 
-			}
+	text, code := h.Split()
+	offs := 0
 
-			prevPos = richTok.pos.Offset + len(richTok.lit)
+	for i, c := range code {
+		offs += len(text[i]) + 2
+
+		injections = append(injections, Injection{
+			Code: strings.TrimSpace(c),
+			Pos:  h.Position(offs + len(c) - len(strings.TrimLeft(c, " \t\r\n"))),
+		})
+
+		offs += len(c) + 2
+	}
+
+	return injections
+}
+
+// Position returns the position in the source of an offset in Body.
+func (h Heredoc) Position(offset int) Position {
+	pos := position(h.Body, offset)
+
+	if pos.Line == 1 {
+		pos.Column += h.Pos.Column - 1
+	}
+
+	pos.Offset += h.Pos.Offset
+	pos.Line += h.Pos.Line - 1
+
+	return pos
+}
+
+// Split splits the body of the HEREDOC into the literal text around
+// injections and the raw code of each injection. text always has one more
+// element than code.
+func (h Heredoc) Split() (text []string, code []string) {
+	body := string(h.Body)
+
+	for {
+		start := strings.Index(body, "{{")
+
+		if start < 0 {
+			break
+		}
+
+		end := strings.Index(body[start+2:], "}}")
+
+		if end < 0 {
+			break
 		}
+
+		text = append(text, body[:start])
+		code = append(code, body[start+2:start+2+end])
+
+		body = body[start+2+end+2:]
 	}
 
-	t.res = append(t.res, t.src[prevPos:t.scanner.srcPos.Offset]...)
+	return append(text, body), code
+}
 
-	return t.res
+// position computes the line and column of an offset in src
+func position(src []byte, offset int) Position {
+	pos := Position{
+		Offset: offset,
+		Line:   1,
+		Column: 1,
+	}
+
+	for _, r := range string(src[:offset]) {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+
+	return pos
 }
@@ -40,7 +40,15 @@ func TestTranslateToString(t *testing.T) {
 var testToJSONs = []transTest{
 	{
 		in:  "<<json\n{\nfoo:{{bar.foo}}\n}\njson>>",
-		out: "json.Inject(`\n{\nfoo:{{bar.foo}}\n}\n`,bar.foo)",
+		out: "json.InjectNamed(`\n{\nfoo:{{bar.foo}}\n}\n`, json.Bindings{\"bar.foo\": bar.foo})",
+	},
+	{
+		in:  "<<a.json\n[{{ x }}, {{y}}, {{x}}]\na.json>>\n<<b.json\n{{z}}\nb.json>>",
+		out: "json.InjectNamed(`\n[{{ x }}, {{y}}, {{x}}]\n`, json.Bindings{\"x\": x, \"y\": y})\njson.InjectNamed(`\n{{z}}\n`, json.Bindings{\"z\": z})",
+	},
+	{
+		in:  "<<json\n{}\njson>>",
+		out: "json.InjectNamed(`\n{}\n`, json.Bindings{})",
 	},
 }
 
@@ -57,3 +65,63 @@ func TestTranslateToJSON(t *testing.T) {
 		}
 	}
 }
+
+var testTranslates = []transTest{
+	{
+		in:  "<<deploy.yaml\nimage: nginx:{{tag}}\ndeploy.yaml>>\n<<a.json\n{{x}}\na.json>>",
+		out: "yaml.InjectNamed(`\nimage: nginx:{{tag}}\n`, yaml.Bindings{\"tag\": tag})\njson.InjectNamed(`\n{{x}}\n`, json.Bindings{\"x\": x})",
+	},
+	{
+		in:  "<<YML\nfoo: bar\nYML>>",
+		out: "yaml.InjectNamed(`\nfoo: bar\n`, yaml.Bindings{})",
+	},
+	{
+		in:  "<<test\n{}\ntest>>",
+		out: "json.InjectNamed(`\n{}\n`, json.Bindings{})",
+	},
+}
+
+func TestTranslate(t *testing.T) {
+	for _, c := range testTranslates {
+		bytes := []byte(c.in)
+
+		trans := NewTranslator(bytes)
+
+		res := trans.Translate()
+
+		if string(res) != c.out {
+			t.Errorf("(%s).Translate() expected %s, got %s", c.in, c.out, res)
+		}
+	}
+}
+
+func TestHeredocs(t *testing.T) {
+	src := "func main() {\n\tx := <<a.json\n{ {{foo}}: 1 }\na.json>>\n\ty := <<b.yaml\nfoo: bar\nb.yaml>>\n}"
+
+	trans := NewTranslator([]byte(src))
+
+	heredocs := trans.Heredocs()
+
+	want := []Heredoc{
+		{
+			Name: "a.json",
+			Body: []byte("\n{ {{foo}}: 1 }\n"),
+			Pos:  Position{Offset: 28, Line: 2, Column: 15},
+		},
+		{
+			Name: "b.yaml",
+			Body: []byte("\nfoo: bar\n"),
+			Pos:  Position{Offset: 67, Line: 5, Column: 15},
+		},
+	}
+
+	if len(heredocs) != len(want) {
+		t.Fatalf("(%s).Heredocs() expected %d heredocs, got %d", src, len(want), len(heredocs))
+	}
+
+	for i, h := range heredocs {
+		if h.Name != want[i].Name || string(h.Body) != string(want[i].Body) || h.Pos != want[i].Pos {
+			t.Errorf("(%s).Heredocs() expected %v, got %v", src, want[i], h)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package yaml
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// ToYAML converts a Porter value to a YAML document. Object keys are sorted,
+// so the same value always produces the same document.
+func ToYAML(v1 v.Value) (string, error) {
+	node, err := fromValue(v1)
+
+	if err != nil {
+		return "", err
+	}
+
+	res, err := yaml.Marshal(node)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(res), nil
+}
+
+// fromValue converts a Porter value to a value that the yaml package encodes,
+// using a yaml.MapSlice with sorted keys for objects
+func fromValue(v1 v.Value) (interface{}, error) {
+	if v1 == nil {
+		return nil, nil
+	}
+
+	switch val := v1.(type) {
+	case v.Boolean:
+		return bool(val), nil
+	case v.Float:
+		return float64(val), nil
+	case v.Integer:
+		return int(val), nil
+	case v.String:
+		return string(val), nil
+	case v.Array:
+		arr := make([]interface{}, 0, len(val))
+
+		for _, elem := range val {
+			node, err := fromValue(elem)
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, node)
+		}
+
+		return arr, nil
+	case v.Object:
+		keys := make([]string, 0, len(val))
+
+		for k := range val {
+			keys = append(keys, string(k))
+		}
+
+		sort.Strings(keys)
+
+		obj := make(yaml.MapSlice, 0, len(keys))
+
+		for _, k := range keys {
+			node, err := fromValue(val[v.String(k)])
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, yaml.MapItem{
+				Key:   k,
+				Value: node,
+			})
+		}
+
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("Value does not contain a supported Porter type")
+}
@@ -0,0 +1,84 @@
+package yaml
+
+import (
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type encoderTest struct {
+	name string
+	val  v.Value
+	want string
+}
+
+var encoderTestsPass = []encoderTest{
+	encoderTest{
+		name: "Encoder: scalar",
+		val:  v.String("hello"),
+		want: "hello\n",
+	},
+	encoderTest{
+		name: "Encoder: sorted keys",
+		val: v.Object{
+			"kind": v.String("Service"),
+			"spec": v.Object{
+				"ports": v.Array{
+					v.Integer(80),
+					v.Float(1.5),
+				},
+				"headless": v.Boolean(false),
+				"selector": nil,
+			},
+			"apiVersion": v.String("v1"),
+		},
+		want: `apiVersion: v1
+kind: Service
+spec:
+  headless: false
+  ports:
+  - 80
+  - 1.5
+  selector: null
+`,
+	},
+	encoderTest{
+		name: "Encoder: ambiguous strings are quoted",
+		val: v.Array{
+			v.String("80"),
+			v.String("true"),
+			v.String("{{x}}"),
+		},
+		want: "- \"80\"\n- \"true\"\n- '{{x}}'\n",
+	},
+}
+
+func TestEncoderPass(t *testing.T) {
+	for _, c := range encoderTestsPass {
+		got, err := ToYAML(c.val)
+
+		if err != nil || got != c.want {
+			t.Errorf("Failed on: %s, %v, %q, %q", c.name, err, got, c.want)
+		}
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	for _, c := range encoderTestsPass {
+		got, _ := ToYAML(c.val)
+
+		res, err := Parse([]byte(got))
+
+		if err != nil || !v.IsEqual(res, c.val) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.val, err)
+		}
+	}
+}
+
+func TestEncoderUnsupportedType(t *testing.T) {
+	_, err := ToYAML(v.Array{"not a porter string"})
+
+	if err == nil {
+		t.Errorf("Expected error encoding unsupported type")
+	}
+}
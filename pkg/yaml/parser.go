@@ -0,0 +1,208 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/porterdev/ego/internal/inject"
+	v "github.com/porterdev/ego/internal/value"
+)
+
+// Bindings maps the code inside each injection {{}} to the value injected in
+// its place, see inject.Bindings
+type Bindings = inject.Bindings
+
+// Parse creates a Porter Value from a YAML stream, see decode. The stream has
+// no injections, so text that looks like a placeholder is kept as is.
+func Parse(src []byte) (v.Value, error) {
+	return decode(src, nil)
+}
+
+// InjectNamed takes in a YAML document that contains Porter injection syntax {{}}
+// and creates a Porter Value, binding each injection to a value by its name. It
+// is an error for an injection to have no binding, or for a binding to be unused.
+func InjectNamed(src string, b Bindings) (v.Value, error) {
+	return inject.Named(b, func(resolve func(name string) (v.Value, error)) (v.Value, error) {
+		return InjectFunc(src, resolve)
+	})
+}
+
+// InjectFunc takes in a YAML document that contains Porter injection syntax {{}}
+// and creates a Porter Value, calling resolve with the name of each injection
+// to get its value.
+//
+// An injection that makes up a whole plain scalar is replaced by its value. An
+// injection inside of a larger scalar, or that makes up a whole quoted scalar,
+// is converted to a string and concatenated with the rest of the scalar. A
+// stream of several documents is decoded to an array, see decode.
+func InjectFunc(src string, resolve func(name string) (v.Value, error)) (v.Value, error) {
+	doc, injections, err := replaceInjections(src, resolve)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decode([]byte(doc), injections)
+}
+
+// decode decodes each document of a YAML stream to a Porter Value. A stream
+// of a single document is decoded to the value of the document, and a stream
+// of several documents, e.g. a multi-document manifest, to an array of the
+// values of the documents. Empty documents are skipped.
+func decode(src []byte, injections []inject.Injection) (v.Value, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	docs := v.Array{}
+
+	for {
+		var doc interface{}
+
+		err := dec.Decode(&doc)
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if doc == nil {
+			continue
+		}
+
+		val, err := toValue(doc, injections)
+
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, val)
+	}
+
+	switch len(docs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return docs[0], nil
+	}
+
+	return docs, nil
+}
+
+// replaceInjections replaces each injection in src with a placeholder, and
+// resolves the value of each injection in order of appearance
+func replaceInjections(src string, resolve func(name string) (v.Value, error)) (string, []inject.Injection, error) {
+	if err := inject.CheckReserved(src); err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var injections []inject.Injection
+
+	offs := 0
+
+	for {
+		start := strings.Index(src[offs:], "{{")
+
+		if start < 0 {
+			break
+		}
+
+		start += offs
+
+		end := strings.Index(src[start+2:], "}}")
+
+		if end < 0 {
+			return "", nil, inject.ErrorAt(src, len(src), "No closing injection }} found")
+		}
+
+		end += start + 2
+
+		name := strings.TrimSpace(src[start+2 : end])
+		val, err := resolve(name)
+
+		if err != nil {
+			return "", nil, inject.ErrorAt(src, start, err.Error())
+		}
+
+		b.WriteString(src[offs:start])
+		b.WriteString(inject.Placeholder(len(injections)))
+
+		injections = append(injections, inject.Injection{
+			Name:   name,
+			Val:    val,
+			Quoted: start > 0 && end+2 < len(src) && strings.ContainsAny(src[start-1:start], `"'`) && src[start-1] == src[end+2],
+		})
+
+		offs = end + 2
+	}
+
+	b.WriteString(src[offs:])
+
+	return b.String(), injections, nil
+}
+
+// toValue converts a value decoded by the yaml package to a Porter Value,
+// replacing placeholders with the injected values
+func toValue(node interface{}, injections []inject.Injection) (v.Value, error) {
+	switch n := node.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return v.Boolean(n), nil
+	case int:
+		return v.Integer(n), nil
+	case int64:
+		return v.Integer(n), nil
+	case uint64:
+		return v.Float(n), nil
+	case float64:
+		return v.Float(n), nil
+	case string:
+		return inject.Substitute(n, injections)
+	case []interface{}:
+		arr := make(v.Array, 0, len(n))
+
+		for _, elem := range n {
+			val, err := toValue(elem, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, val)
+		}
+
+		return arr, nil
+	case map[interface{}]interface{}:
+		obj := make(v.Object, len(n))
+
+		for k, elem := range n {
+			key, err := toValue(k, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			str, err := v.Stringify(key)
+
+			if err != nil {
+				return nil, fmt.Errorf("Keys must be a string, number, boolean or null")
+			}
+
+			val, err := toValue(elem, injections)
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj[v.String(str)] = val
+		}
+
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported YAML type %T", node)
+}
@@ -0,0 +1,273 @@
+package yaml
+
+import (
+	"testing"
+
+	v "github.com/porterdev/ego/internal/value"
+)
+
+type parseTest struct {
+	name string
+	yaml string
+	want v.Value
+}
+
+var parseTestsPass = []parseTest{
+	parseTest{
+		name: "Parse: scalars",
+		yaml: "a: 1\nb: 1.5\nc: true\nd: null\ne: hello\nf: \"2\"\n",
+		want: v.Object{
+			"a": v.Integer(1),
+			"b": v.Float(1.5),
+			"c": v.Boolean(true),
+			"d": nil,
+			"e": v.String("hello"),
+			"f": v.String("2"),
+		},
+	},
+	parseTest{
+		name: "Parse: nested manifest",
+		yaml: `apiVersion: apps/v1
+kind: Deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        ports:
+        - containerPort: 80
+`,
+		want: v.Object{
+			"apiVersion": v.String("apps/v1"),
+			"kind":       v.String("Deployment"),
+			"spec": v.Object{
+				"replicas": v.Integer(3),
+				"template": v.Object{
+					"spec": v.Object{
+						"containers": v.Array{
+							v.Object{
+								"name": v.String("web"),
+								"ports": v.Array{
+									v.Object{
+										"containerPort": v.Integer(80),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+	parseTest{
+		name: "Parse: non-string keys",
+		yaml: "80: http\ntrue: yes\n",
+		want: v.Object{
+			"80":   v.String("http"),
+			"true": v.Boolean(true),
+		},
+	},
+	parseTest{
+		name: "Parse: multiple documents",
+		yaml: "---\nkind: Service\n---\nkind: Deployment\n---\n",
+		want: v.Array{
+			v.Object{
+				"kind": v.String("Service"),
+			},
+			v.Object{
+				"kind": v.String("Deployment"),
+			},
+		},
+	},
+	parseTest{
+		name: "Parse: placeholder text",
+		yaml: "a: __porter_inject_3__\nb: x-__porter_inject_0__\n",
+		want: v.Object{
+			"a": v.String("__porter_inject_3__"),
+			"b": v.String("x-__porter_inject_0__"),
+		},
+	},
+}
+
+func TestParsePass(t *testing.T) {
+	for _, c := range parseTestsPass {
+		res, err := Parse([]byte(c.yaml))
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+type injectNamedTest struct {
+	name     string
+	yaml     string
+	bindings Bindings
+	want     v.Value
+	msg      string
+}
+
+var injectNamedTestsPass = []injectNamedTest{
+	injectNamedTest{
+		name: "InjectNamed: whole values",
+		yaml: "replicas: {{ replicas }}\nlabels: {{labels}}\nports: [{{port}}, {{port}}]\n",
+		bindings: Bindings{
+			"replicas": v.Integer(2),
+			"labels": v.Object{
+				"app": v.String("web"),
+			},
+			"port": v.Integer(80),
+		},
+		want: v.Object{
+			"replicas": v.Integer(2),
+			"labels": v.Object{
+				"app": v.String("web"),
+			},
+			"ports": v.Array{
+				v.Integer(80),
+				v.Integer(80),
+			},
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: interpolation",
+		yaml: "image: nginx:{{tag}}\n\"{{app}}-svc\": '{{app}}:{{port}}'\n",
+		bindings: Bindings{
+			"tag":  v.String("1.19"),
+			"app":  v.String("web"),
+			"port": v.Integer(80),
+		},
+		want: v.Object{
+			"image":   v.String("nginx:1.19"),
+			"web-svc": v.String("web:80"),
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: quoted injections",
+		yaml: "port: \"{{port}}\"\nreplicas: '{{ replicas }}'\nready: {{ready}}\n",
+		bindings: Bindings{
+			"port":     v.Integer(80),
+			"replicas": v.Integer(2),
+			"ready":    v.Boolean(true),
+		},
+		want: v.Object{
+			"port":     v.String("80"),
+			"replicas": v.String("2"),
+			"ready":    v.Boolean(true),
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: multiple documents",
+		yaml: "name: {{name}}\n---\nname: {{name}}-svc\n",
+		bindings: Bindings{
+			"name": v.String("web"),
+		},
+		want: v.Array{
+			v.Object{
+				"name": v.String("web"),
+			},
+			v.Object{
+				"name": v.String("web-svc"),
+			},
+		},
+	},
+	injectNamedTest{
+		name: "InjectNamed: key injection",
+		yaml: "{{key}}:\n  - {{val}}\n",
+		bindings: Bindings{
+			"key": v.String("foo"),
+			"val": v.String("bar"),
+		},
+		want: v.Object{
+			"foo": v.Array{
+				v.String("bar"),
+			},
+		},
+	},
+}
+
+func TestInjectNamedPass(t *testing.T) {
+	for _, c := range injectNamedTestsPass {
+		res, err := InjectNamed(c.yaml, c.bindings)
+
+		if err != nil || !v.IsEqual(res, c.want) {
+			t.Errorf("Failed on: %s, %v, %v, %v", c.name, res, c.want, err)
+		}
+	}
+}
+
+var injectNamedTestsFail = []injectNamedTest{
+	injectNamedTest{
+		name:     "InjectNamed: unknown name",
+		yaml:     "a: 1\nb: {{foo}}\n",
+		bindings: Bindings{},
+		msg:      "Column 4, Line 2: No value bound to injection foo",
+	},
+	injectNamedTest{
+		name: "InjectNamed: unused bindings",
+		yaml: "a: {{foo}}\n",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+			"qux": v.Integer(2),
+			"bar": v.Integer(3),
+		},
+		msg: "Bindings not used by any injection: bar, qux",
+	},
+	injectNamedTest{
+		name: "InjectNamed: no closing injection",
+		yaml: "a: {{foo\n",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+		},
+		msg: "Column 1, Line 2: No closing injection }} found",
+	},
+	injectNamedTest{
+		name: "InjectNamed: interpolated object",
+		yaml: "a: x-{{foo}}\n",
+		bindings: Bindings{
+			"foo": v.Object{},
+		},
+		msg: "Injection foo inside a string must be a string, number, boolean or null",
+	},
+	injectNamedTest{
+		name: "InjectNamed: quoted object",
+		yaml: "a: \"{{foo}}\"\n",
+		bindings: Bindings{
+			"foo": v.Object{},
+		},
+		msg: "Injection foo inside a string must be a string, number, boolean or null",
+	},
+	injectNamedTest{
+		name: "InjectNamed: invalid second document",
+		yaml: "a: {{foo}}\n---\n b: c\nd: e\n",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+		},
+		msg: "yaml: line 3: did not find expected <document start>",
+	},
+	injectNamedTest{
+		name:     "InjectNamed: reserved text",
+		yaml:     "a: __porter_inject_0__\n",
+		bindings: Bindings{},
+		msg:      "Document cannot contain the reserved text __porter_inject_",
+	},
+	injectNamedTest{
+		name: "InjectNamed: invalid yaml",
+		yaml: "a: {{foo}}\n b: c\n",
+		bindings: Bindings{
+			"foo": v.Integer(1),
+		},
+		msg: "yaml: line 2: mapping values are not allowed in this context",
+	},
+}
+
+func TestInjectNamedFail(t *testing.T) {
+	for _, c := range injectNamedTestsFail {
+		_, err := InjectNamed(c.yaml, c.bindings)
+
+		if err == nil || err.Error() != c.msg {
+			t.Errorf("Failed on: %s, input %v, expected %v, got %v", c.name, c.yaml, c.msg, err)
+		}
+	}
+}